@@ -1,12 +1,16 @@
 package common
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
-// The public members of this struct are only set during Run, not during Finalize
+// The public members of this struct are only set during Run, not during Finalize.
+// Finalize looks at other files through Facts, Files and ReportAt instead.
+// Content and Root are shared between all plugins handling the same file and must not be modified.
 type Analysis struct {
 	Content   []byte
 	Root      *sitter.Node
@@ -14,7 +18,45 @@ type Analysis struct {
 	Extension string
 
 	pluginName string
+	config     *Config
 	violations []Violation
+	ctx        context.Context
+	state      []byte
+	facts      []storedFact
+	finalize   *finalizeData
+}
+
+// Context is cancelled when the run is cancelled or the plugin exceeded Options.Timeout for the file.
+// Plugins doing a lot of work should check it, FindMatches does so on its own.
+func (a *Analysis) Context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// DecodeConfig decodes the plugin's section of the project configuration into v.
+// If there is no such section v is left untouched, so it should be filled with the defaults beforehand.
+func (a *Analysis) DecodeConfig(v any) error {
+	return a.config.decodePluginSection(a.pluginName, v)
+}
+
+// Persist stores state about the current file in the cache, it's passed to Plugin.Restore when a later run skips the file.
+// Plugins with Finalize use it for what they collect in Run, only the last call for a file counts.
+func (a *Analysis) Persist(state []byte) {
+	a.state = state
+}
+
+// FindMatches runs the query against the whole file, matches are in the order they appear in the file
+func (a *Analysis) FindMatches(q *Query) ([]Match, error) {
+	if a.Root == nil {
+		return nil, errors.New("queries can only be run during Run")
+	}
+	lang := getLanguage(a.Extension)
+	if lang == nil {
+		return nil, fmt.Errorf("unknown extension %s", a.Extension)
+	}
+	return runQuery(a.Context(), q, lang, a.Root, a.Content)
 }
 
 func (a *Analysis) Report(n *sitter.Node, msg string) {
@@ -35,6 +77,43 @@ func (a *Analysis) Reportf(n *sitter.Node, format string, args ...any) {
 	a.ReportCodef(n, "", format, args...)
 }
 
+// ReportCapture reports the first node captured with the given name, if there isn't any the violation applies to the whole file
+func (a *Analysis) ReportCapture(m Match, capture string, msg string) {
+	a.ReportCaptureCode(m, capture, "", msg)
+}
+
+func (a *Analysis) ReportCaptureCode(m Match, capture string, errorCode string, msg string) {
+	a.ReportCode(m.Capture(capture), errorCode, msg)
+}
+
+func (a *Analysis) ReportCaptureCodef(m Match, capture string, errorCode string, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	a.ReportCaptureCode(m, capture, errorCode, msg)
+}
+
+func (a *Analysis) ReportCapturef(m Match, capture string, format string, args ...any) {
+	a.ReportCaptureCodef(m, capture, "", format, args...)
+}
+
+// ReportWithFix reports a violation that is fixed by applying all the edits, see ReplaceNode
+func (a *Analysis) ReportWithFix(n *sitter.Node, msg string, edits ...Edit) {
+	a.ReportCodeWithFix(n, "", msg, edits...)
+}
+
+func (a *Analysis) ReportCodeWithFix(n *sitter.Node, errorCode string, msg string, edits ...Edit) {
+	v := newViolation(a.pluginName, a.FilePath, n, a.Content, errorCode, msg)
+	v.Edits = newEdits(a.Content, edits)
+	a.violations = append(a.violations, v)
+}
+
+func (a *Analysis) ReportCaptureWithFix(m Match, capture string, msg string, edits ...Edit) {
+	a.ReportCaptureCodeWithFix(m, capture, "", msg, edits...)
+}
+
+func (a *Analysis) ReportCaptureCodeWithFix(m Match, capture string, errorCode string, msg string, edits ...Edit) {
+	a.ReportCodeWithFix(m.Capture(capture), errorCode, msg, edits...)
+}
+
 func (a *Analysis) ReportFile(file string, msg string) {
 	a.ReportFileCode(file, "", msg)
 }
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Baseline records violations that are known, e.g. when adopting a plugin on existing code, they don't fail a run.
+type Baseline struct {
+	Entries []BaselineEntry `json:"entries"`
+
+	// file paths of the entries are relative to this directory
+	root string
+}
+
+type BaselineEntry struct {
+	Plugin string `json:"plugin"`
+	Code   string `json:"code,omitempty"`
+	File   string `json:"file"`
+
+	// Fingerprint is derived from the content of the violating lines, so the entry still matches if lines are added above
+	Fingerprint string `json:"fingerprint"`
+
+	// Message is only there for the humans reading the file
+	Message string `json:"message"`
+}
+
+// NewBaseline records all violations without a justification, path is the file the baseline is going to be written to
+func NewBaseline(path string, violations []Violation) (*Baseline, error) {
+	b, err := newEmptyBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, vio := range violations {
+		// failures of the run aren't known violations, they have to be fixed
+		if vio.Justification != nil || vio.PluginName == internalPlugin.Name {
+			continue
+		}
+		b.Entries = append(b.Entries, b.entry(vio))
+	}
+	sort.SliceStable(b.Entries, func(i, j int) bool {
+		x := b.Entries[i]
+		y := b.Entries[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		if x.Plugin != y.Plugin {
+			return x.Plugin < y.Plugin
+		}
+		if x.Code != y.Code {
+			return x.Code < y.Code
+		}
+		return x.Fingerprint < y.Fingerprint
+	})
+	return b, nil
+}
+
+func LoadBaseline(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read baseline %s: %s", path, err)
+	}
+	b, err := newEmptyBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse baseline %s: %s", path, err)
+	}
+	return b, nil
+}
+
+func newEmptyBaseline(path string) (*Baseline, error) {
+	root, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to use baseline %s: %s", path, err)
+	}
+	return &Baseline{Entries: []BaselineEntry{}, root: root}, nil
+}
+
+func (b *Baseline) Write(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to write baseline %s: %s", path, err)
+	}
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write baseline %s: %s", path, err)
+	}
+	return nil
+}
+
+// Filter splits off the violations that are recorded in the baseline.
+// Every entry matches a single violation only, so new violations on identical lines are still found.
+// Entries that don't match any violation anymore are returned as stale, they can be removed from the baseline.
+func (b *Baseline) Filter(violations []Violation) (remaining []Violation, known []Violation, stale []BaselineEntry) {
+	unmatched := map[BaselineEntry]int{}
+	for _, entry := range b.Entries {
+		entry.Message = ""
+		unmatched[entry]++
+	}
+	remaining = []Violation{}
+	known = []Violation{}
+	for _, vio := range violations {
+		entry := b.entry(vio)
+		entry.Message = ""
+		if vio.Justification == nil && unmatched[entry] > 0 {
+			unmatched[entry]--
+			known = append(known, vio)
+			continue
+		}
+		remaining = append(remaining, vio)
+	}
+
+	stale = []BaselineEntry{}
+	for _, entry := range b.Entries {
+		key := entry
+		key.Message = ""
+		if unmatched[key] > 0 {
+			unmatched[key]--
+			stale = append(stale, entry)
+		}
+	}
+	return remaining, known, stale
+}
+
+func (b *Baseline) entry(v Violation) BaselineEntry {
+	file := v.FilePath
+	if file != "" {
+		abs, err := filepath.Abs(file)
+		if err == nil {
+			rel, err := filepath.Rel(b.root, abs)
+			if err == nil {
+				file = rel
+			}
+		}
+		file = filepath.ToSlash(file)
+	}
+	return BaselineEntry{
+		Plugin:      v.PluginName,
+		Code:        v.ErrorCode,
+		File:        file,
+		Fingerprint: v.fingerprint(),
+		Message:     v.Message,
+	}
+}
+
+// fingerprint hashes the violating lines without surrounding whitespace, violations for whole files use the message instead
+func (v Violation) fingerprint() string {
+	text := v.Message
+	lines := []string{}
+	for line := v.StartLine; line <= v.EndLine; line++ {
+		content, found := v.relevantLine(line)
+		if !found {
+			lines = nil
+			break
+		}
+		lines = append(lines, strings.TrimSpace(content))
+	}
+	if len(lines) > 0 {
+		text = strings.Join(lines, "\n")
+	}
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:16])
+}
+
+// covers reports whether the entry belongs to a file within one of the paths, stale entries outside of the analyzed paths are expected
+func (b *Baseline) covers(entry BaselineEntry, paths []string) bool {
+	if entry.File == "" {
+		return true
+	}
+	file := filepath.Join(b.root, filepath.FromSlash(entry.File))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(abs, file)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
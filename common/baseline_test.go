@@ -0,0 +1,83 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		"a.go": "package foo\n\nfunc old() {}\n\nfunc noFix() {}\n",
+		"b.go": "package foo\n\nfunc old() {}\n",
+	})
+	plugins := []*Plugin{renamePlugin}
+	violations, err := RunChecksForFiles(plugins, []string{root}, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	path := filepath.Join(root, "baseline.json")
+	baseline, err := NewBaseline(path, violations)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	err = baseline.Write(path)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	baseline, err = LoadBaseline(path)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(baseline.Entries) != 3 || baseline.Entries[0].File != "a.go" || baseline.Entries[1].Code != "R001" || baseline.Entries[2].File != "b.go" {
+		fmt.Printf("baseline.Entries: %#v\n", baseline.Entries)
+		t.FailNow()
+	}
+
+	// shifting lines keeps the entries matching, a second identical violation is new and the removed violation is stale
+	writeTestTree(t, root, map[string]string{
+		"a.go": "package foo\n\n// a comment\n\nfunc old() {}\n\nfunc noFix() {}\n\nfunc old() {}\n",
+		"b.go": "package foo\n",
+	})
+	violations, err = RunChecksForFiles(plugins, []string{root}, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	remaining, known, stale := baseline.Filter(violations)
+	if len(known) != 2 || len(remaining) != 1 || remaining[0].StartLine != 8 || len(stale) != 1 || stale[0].File != "b.go" {
+		fmt.Printf("remaining: %v\n", remaining)
+		fmt.Printf("known: %v\n", known)
+		fmt.Printf("stale: %v\n", stale)
+		t.Fail()
+	}
+	if !baseline.covers(stale[0], []string{root}) || baseline.covers(stale[0], []string{filepath.Join(root, "a.go")}) {
+		t.Fail()
+	}
+
+	// the file paths don't depend on the working directory
+	wd, _ := os.Getwd()
+	err = os.Chdir(root)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	defer os.Chdir(wd)
+	violations, err = RunChecksForFiles(plugins, []string{"a.go"}, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	remaining, known, _ = baseline.Filter(violations)
+	if len(known) != 2 || len(remaining) != 1 {
+		fmt.Printf("remaining: %v\n", remaining)
+		t.Fail()
+	}
+}
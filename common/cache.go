@@ -0,0 +1,193 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cacheFormat is part of every cache key, it has to be increased whenever the cached data or the built-in checks change
+const cacheFormat = 1
+
+// cache stores the results of every plugin per file in a directory, see Options.CacheDir.
+// Entries are never updated, a changed file, plugin or configuration results in a new key.
+type cache struct {
+	dir string
+}
+
+type cacheEntry struct {
+	Violations []cachedViolation
+
+	// State is what the plugin passed to Analysis.Persist, it's handed to Plugin.Restore
+	State []byte
+
+	Facts []storedFact
+}
+
+// cachedViolation keeps the justifications gob would drop as unexported fields
+type cachedViolation struct {
+	Violation
+	ExpiredJustification *Justification
+	UnusedJustification  *Justification
+}
+
+func openCache(dir string) (*cache, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache directory %s: %s", dir, err)
+	}
+	// the cache is never meant to be committed
+	ignore := filepath.Join(dir, ".gitignore")
+	_, err = os.Stat(ignore)
+	if os.IsNotExist(err) {
+		err = os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache directory %s: %s", dir, err)
+	}
+	return &cache{dir: dir}, nil
+}
+
+// cacheable plugins have a version, plugins with Finalize also have to be able to restore their state
+func (p *Plugin) cacheable() bool {
+	return p.Version != "" && (p.Finalize == nil || p.Restore != nil || p.FactsOnly)
+}
+
+// fileKey covers the file, its content and its configuration, it's empty without a cache
+func (c *cache) fileKey(file sourceFile, content []byte) string {
+	if c == nil {
+		return ""
+	}
+	return hashFields(fmt.Sprint(cacheFormat), file.path, hashContent(content), file.config.hash())
+}
+
+// key identifies the results of a plugin for a file, extra covers everything else the results depend on
+func (c *cache) key(fileKey string, pluginName string, pluginVersion string, extra ...string) string {
+	if fileKey == "" {
+		return ""
+	}
+	return hashFields(append([]string{fileKey, pluginName, pluginVersion}, extra...)...)
+}
+
+func hashFields(fields ...string) string {
+	h := sha256.New()
+	for _, field := range fields {
+		// the length prefix keeps fields from running into each other
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key[2:])
+}
+
+// get reports a miss for entries that are missing or unreadable, e.g. written by an older version.
+// Both get and put do nothing for an empty key, so callers don't have to check whether there is a cache.
+func (c *cache) get(key string) (*cacheEntry, bool) {
+	if key == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&entry)
+	if err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// put ignores failures, a missing entry only means the file is analyzed again next time
+func (c *cache) put(key string, violations []Violation, state []byte, facts []storedFact) {
+	if key == "" {
+		return
+	}
+	entry := cacheEntry{Violations: []cachedViolation{}, State: state, Facts: facts}
+	for _, v := range violations {
+		entry.Violations = append(entry.Violations, cachedViolation{Violation: v, ExpiredJustification: v.expiredJustification, UnusedJustification: v.unusedJustification})
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(entry)
+	if err != nil {
+		return
+	}
+	path := c.path(key)
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return
+	}
+	// concurrent runs may write the same entry, renaming makes sure nobody reads half of it
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf.Bytes())
+	closeErr := tmp.Close()
+	if err != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// violations of the entry, justifications that expired since they were cached are treated like in newViolation
+func (e *cacheEntry) violations() []Violation {
+	result := []Violation{}
+	for _, cv := range e.Violations {
+		v := cv.Violation
+		v.expiredJustification = cv.ExpiredJustification
+		v.unusedJustification = cv.UnusedJustification
+		if v.Justification != nil && v.Justification.Expired(now()) {
+			v.expireJustification()
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// hash covers everything in the configuration that changes the results, it's empty without configuration
+func (c *Config) hash() string {
+	if c == nil {
+		return ""
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		// never matching any earlier run is better than matching a wrong one
+		return fmt.Sprintf("unhashable %p", c)
+	}
+	return hashContent([]byte(c.Path + "\n" + c.root + "\n" + string(data)))
+}
+
+// justificationCacheExtra describes what the justification check depends on besides the file: the known plugins and the ones that ran
+func justificationCacheExtra(plugins []*Plugin, ran []*Plugin) []string {
+	known := []string{}
+	for _, plugin := range plugins {
+		known = append(known, plugin.Name+"@"+plugin.Version+"("+strings.Join(plugin.ErrorCodes, ",")+")")
+	}
+	sort.Strings(known)
+	names := []string{}
+	for _, plugin := range ran {
+		names = append(names, plugin.Name)
+	}
+	sort.Strings(names)
+	return []string{strings.Join(known, " "), strings.Join(names, " ")}
+}
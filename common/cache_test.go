@@ -0,0 +1,117 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	writeTestTree(t, src, map[string]string{
+		"a.go": "package foo\n\n// JUSTIFY(functions; until=2026-06-30): going away soon\nfunc a() {}\n",
+		"b.go": "package foo\n\nfunc b() {}\n",
+	})
+	now = func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	runs := map[string]int{}
+	restores := 0
+	names := []string{}
+	functions := &Plugin{
+		Name:       "functions",
+		Extensions: []string{"go"},
+		Version:    "1",
+		Serial:     true,
+		Run: func(a *Analysis) error {
+			runs[filepath.Base(a.FilePath)]++
+			found := []string{}
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				a.Report(n, "function")
+				found = append(found, n.ChildByFieldName("name").Content(a.Content))
+			}
+			names = append(names, found...)
+			a.Persist([]byte(strings.Join(found, ",")))
+			return nil
+		},
+		Restore: func(a *Analysis, state []byte) error {
+			restores++
+			names = append(names, strings.Split(string(state), ",")...)
+			return nil
+		},
+		Finalize: func(a *Analysis) error {
+			sort.Strings(names)
+			a.ReportFile("", "functions "+strings.Join(names, " "))
+			names = []string{}
+			return nil
+		},
+	}
+	uncached := &Plugin{
+		Name:       "uncached",
+		Extensions: []string{"go"},
+		Serial:     true,
+		Run: func(a *Analysis) error {
+			runs["uncached"]++
+			return nil
+		},
+	}
+	opts := RunOptions{Options: Options{Jobs: 1, CacheDir: filepath.Join(root, ".check-cache")}, Plugins: []*Plugin{functions, uncached}, Paths: []string{src}}
+	run := func() string {
+		report, err := Run(context.Background(), opts)
+		if err != nil {
+			fmt.Printf("err: %v\n", err)
+			t.FailNow()
+		}
+		result := ""
+		for _, v := range report.Violations() {
+			result += v.String()
+		}
+		return result
+	}
+
+	first := run()
+	if runs["a.go"] != 1 || runs["b.go"] != 1 || restores != 0 || !strings.Contains(first, "functions a b") {
+		fmt.Printf("first: %v %d\n%s", runs, restores, first)
+		t.FailNow()
+	}
+	second := run()
+	if runs["a.go"] != 1 || runs["b.go"] != 1 || runs["uncached"] != 4 || restores != 2 || second != first {
+		fmt.Printf("second: %v %d\n%s", runs, restores, second)
+		t.FailNow()
+	}
+
+	writeTestTree(t, src, map[string]string{"b.go": "package foo\n\nfunc b() {}\n\nfunc c() {}\n"})
+	third := run()
+	if runs["a.go"] != 1 || runs["b.go"] != 2 || restores != 3 || !strings.Contains(third, "functions a b c") {
+		fmt.Printf("third: %v %d\n%s", runs, restores, third)
+		t.FailNow()
+	}
+
+	// the justification expires while the result is cached
+	now = func() time.Time { return time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC) }
+	fourth := run()
+	if runs["a.go"] != 1 || !strings.Contains(fourth, "function (justification expired on 2026-06-30)") || strings.Contains(fourth, "justification(justification/unused)") {
+		fmt.Printf("fourth: %v\n%s", runs, fourth)
+		t.Fail()
+	}
+
+	// a new version of the plugin doesn't use the old results
+	functions.Version = "2"
+	run()
+	if runs["a.go"] != 2 || runs["b.go"] != 3 {
+		fmt.Printf("new version: %v\n", runs)
+		t.Fail()
+	}
+
+	_, err := os.Stat(filepath.Join(root, ".check-cache", ".gitignore"))
+	if err != nil {
+		fmt.Println(err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ChangedLines are the lines added or modified by a diff, it's used to only report violations touching them
+type ChangedLines struct {
+	// lines per file, 0-indexed like the violations, files without lines were only touched by deletions
+	files map[string]map[uint32]bool
+
+	// untracked files aren't part of any diff, all their lines count as changed
+	untracked map[string]bool
+
+	// the file paths of the diff are relative to this directory
+	root string
+}
+
+// ParseUnifiedDiff reads the changed lines from a diff like the one git diff prints, file paths are relative to root.
+// Lines that only got removed aren't part of the new file, a removal only marks the file as touched.
+// The line counts of the hunk headers tell where a hunk ends, so removed and added lines looking like file headers stay part of it.
+func ParseUnifiedDiff(diff []byte, root string) (*ChangedLines, error) {
+	root, err := resolvePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse diff: %s", err)
+	}
+	c := &ChangedLines{files: map[string]map[uint32]bool{}, untracked: map[string]bool{}, root: root}
+	var lines map[uint32]bool
+	line := uint32(0)
+	// the lines of the old and the new file left in the current hunk
+	oldLeft := 0
+	newLeft := 0
+	previous := ""
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if oldLeft > 0 || newLeft > 0 {
+			if strings.HasPrefix(text, "+") {
+				if lines != nil {
+					lines[line] = true
+				}
+				line++
+				newLeft--
+			} else if strings.HasPrefix(text, "-") {
+				oldLeft--
+			} else if strings.HasPrefix(text, " ") || text == "" {
+				line++
+				oldLeft--
+				newLeft--
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("unable to parse diff: hunk is longer than its header says at %s", text)
+			}
+			continue
+		}
+		header := strings.HasPrefix(text, "+++ ") && strings.HasPrefix(previous, "--- ")
+		previous = text
+		if header {
+			path := strings.TrimPrefix(text, "+++ ")
+			// some tools append a timestamp after a tab
+			path, _, _ = strings.Cut(path, "\t")
+			// git quotes paths with unusual characters like a Go string literal
+			if strings.HasPrefix(path, "\"") {
+				unquoted, err := strconv.Unquote(path)
+				if err == nil {
+					path = unquoted
+				}
+			}
+			lines = nil
+			if path == "/dev/null" {
+				continue
+			}
+			path = strings.TrimPrefix(path, "b/")
+			lines = c.files[path]
+			if lines == nil {
+				lines = map[uint32]bool{}
+				c.files[path] = lines
+			}
+			continue
+		}
+		// hunks of deleted files are skipped by their counts as well
+		match := hunkHeaderRegex.FindStringSubmatch(text)
+		if match != nil {
+			// a count is left out for hunks of a single line
+			counts := []int{1, 1}
+			for i, count := range []string{match[1], match[3]} {
+				if count == "" {
+					continue
+				}
+				counts[i], err = strconv.Atoi(count)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse diff: invalid hunk %s", text)
+				}
+			}
+			oldLeft, newLeft = counts[0], counts[1]
+			start, err := strconv.ParseUint(match[2], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse diff: invalid hunk %s", text)
+			}
+			// an empty hunk starts at 0
+			line = 0
+			if start > 0 {
+				line = uint32(start) - 1
+			}
+			continue
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse diff: %s", err)
+	}
+	return c, nil
+}
+
+// ChangedLinesSince asks git for the changes in the working tree of the repository containing dir since the merge base with rev.
+// Files that aren't tracked yet and aren't ignored count as changed entirely.
+func ChangedLinesSince(dir string, rev string) (*ChangedLines, error) {
+	root, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	base, err := runGit(dir, "merge-base", rev, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := runGit(dir, "diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "-U0", strings.TrimSpace(string(base)), "--")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := ParseUnifiedDiff(diff, strings.TrimSpace(string(root)))
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(strings.TrimSpace(string(root)), "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(string(untracked), "\x00") {
+		if path != "" {
+			changes.untracked[path] = true
+		}
+	}
+	return changes, nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run git %s: %s %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Filter keeps the violations overlapping with changed lines.
+// Violations for whole files are kept if the file was touched, violations without a file are always kept.
+func (c *ChangedLines) Filter(violations []Violation) []Violation {
+	result := []Violation{}
+	for _, vio := range violations {
+		if vio.FilePath == "" {
+			result = append(result, vio)
+			continue
+		}
+		rel := c.relative(vio.FilePath)
+		if c.untracked[rel] {
+			result = append(result, vio)
+			continue
+		}
+		lines, found := c.files[rel]
+		if !found {
+			continue
+		}
+		if vio.StartLine == 0 && vio.StartColumn == 0 && vio.EndLine == 0 && vio.EndColumn == 0 {
+			result = append(result, vio)
+			continue
+		}
+		for line := vio.StartLine; line <= vio.EndLine; line++ {
+			if lines[line] {
+				result = append(result, vio)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (c *ChangedLines) relative(path string) string {
+	abs, err := resolvePath(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(c.root, abs)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// resolvePath makes the path absolute and resolves symlinks as far as possible, git reports the resolved root of the repository
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return abs, nil
+	}
+	return resolved, nil
+}
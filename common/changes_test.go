@@ -0,0 +1,138 @@
+package common
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n" +
+		"--- a/a.go\n" +
+		"+++ b/a.go\n" +
+		"@@ -1,3 +1,4 @@\n" +
+		" package foo\n" +
+		"+// added\n" +
+		" \n" +
+		"-func removed() {}\n" +
+		"+func changed() {}\n" +
+		"@@ -10,0 +11,1 @@\n" +
+		"+++ not a header\n" +
+		"--- a/q.sql\n" +
+		"+++ b/q.sql\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		"--- removed comment\n" +
+		"+++ added comment\n" +
+		"+select 1;\n" +
+		" select 2;\n" +
+		"--- a/b.go\n" +
+		"+++ b/b.go\n" +
+		"@@ -2,1 +1,0 @@\n" +
+		"-var x int\n" +
+		"--- a/gone.go\n" +
+		"+++ /dev/null\n" +
+		"@@ -1,3 +0,0 @@\n" +
+		"-package foo\n" +
+		"--- removed comment\n" +
+		"-var y int\n" +
+		"--- a/Gr\\303\\274\\303\\237e.go\n" +
+		"+++ \"b/Gr\\303\\274\\303\\237e.go\"\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+package foo\n"
+	root := t.TempDir()
+	changes, err := ParseUnifiedDiff([]byte(diff), root)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	exp := "map[Grüße.go:map[0:true] a.go:map[1:true 3:true 10:true] b.go:map[] q.sql:map[0:true 1:true]]"
+	if fmt.Sprint(changes.files) != exp {
+		fmt.Printf("exp: %v\n", exp)
+		fmt.Printf("changes.files: %v\n", changes.files)
+		t.Fail()
+	}
+
+	violations := []Violation{
+		{PluginName: "added", FilePath: filepath.Join(root, "a.go"), StartLine: 1, EndLine: 1, EndColumn: 8},
+		{PluginName: "unchanged", FilePath: filepath.Join(root, "a.go"), StartLine: 2, EndLine: 2, EndColumn: 1},
+		{PluginName: "spanning", FilePath: filepath.Join(root, "a.go"), StartLine: 2, EndLine: 3, EndColumn: 1},
+		{PluginName: "file", FilePath: filepath.Join(root, "b.go")},
+		{PluginName: "untouched", FilePath: filepath.Join(root, "c.go")},
+		{PluginName: "global"},
+	}
+	names := []string{}
+	for _, v := range changes.Filter(violations) {
+		names = append(names, v.PluginName)
+	}
+	if fmt.Sprint(names) != "[added spanning file global]" {
+		fmt.Printf("names: %v\n", names)
+		t.Fail()
+	}
+
+	_, err = ParseUnifiedDiff([]byte("--- a/a.go\n+++ b/a.go\n@@ -1,0 +1 @@\n-a\n+b\n"), root)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestChangedLinesSince(t *testing.T) {
+	root := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			fmt.Printf("git %v: %s %s\n", args, err, out)
+			t.FailNow()
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "test")
+	writeTestTree(t, root, map[string]string{
+		"a.go":     "package foo\n\nfunc old() {}\n",
+		"sub/b.go": "package foo\n\nfunc old() {}\n",
+	})
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "feature")
+	writeTestTree(t, root, map[string]string{
+		"a.go":     "package foo\n\nfunc old() {}\n\nfunc noFix() {}\n",
+		"sub/b.go": "package foo\n\n// changed\nfunc old() {}\n",
+	})
+	git("commit", "-q", "-a", "-m", "feature")
+	// uncommitted changes in the working tree count as well
+	writeTestTree(t, root, map[string]string{
+		"sub/c.go": "package foo\n\nfunc old() {}\n",
+	})
+	git("add", "sub/c.go")
+	// new files count entirely before they are added
+	writeTestTree(t, root, map[string]string{
+		"new.go": "package foo\n\nfunc old() {}\n",
+	})
+
+	changes, err := ChangedLinesSince(filepath.Join(root, "sub"), "main")
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	violations, err := RunChecksForDirectories([]*Plugin{renamePlugin}, []string{root})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	messages := []string{}
+	for _, v := range changes.Filter(violations) {
+		messages = append(messages, fmt.Sprintf("%s:%d %s", filepath.ToSlash(v.FilePath[len(root)+1:]), v.StartLine, v.Message))
+	}
+	if fmt.Sprint(messages) != "[a.go:4 noFix is deprecated new.go:2 old is deprecated sub/c.go:2 old is deprecated]" {
+		fmt.Printf("messages: %v\n", messages)
+		t.Fail()
+	}
+
+	_, err = ChangedLinesSince(root, "missing")
+	if err == nil {
+		t.Fail()
+	}
+}
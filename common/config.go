@@ -0,0 +1,183 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFileName = ".check.yaml"
+
+// Config is the project configuration, it's read from the file .check.yaml
+type Config struct {
+	// Path is the file the configuration was read from, empty if there is none
+	Path string `yaml:"-"`
+
+	// Output is the default output format, the command line flag takes precedence
+	Output string `yaml:"output"`
+
+	// If Enable isn't empty only these plugins are run, plugins in Disable are never run
+	Enable  []string `yaml:"enable"`
+	Disable []string `yaml:"disable"`
+
+	// Globs are matched against paths relative to the directory containing the configuration.
+	// If Include isn't empty only matching files are analyzed, matching files and directories in Exclude are skipped.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+
+	// Severity overrides the level of violations per plugin or per plugin and error code, e.g. unwanted-imports/E002
+	Severity map[string]Severity `yaml:"severity"`
+
+	// Plugins holds an arbitrary section per plugin name, see Analysis.DecodeConfig
+	Plugins map[string]yaml.Node `yaml:"plugins"`
+
+	// all relative paths are resolved against this directory
+	root string
+}
+
+// FindConfig looks for .check.yaml in the directory and all of its parents.
+// If there is none an empty configuration is returned.
+func FindConfig(start string) (*Config, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find config for %s: %s", dir, err)
+	}
+	for {
+		path := filepath.Join(dir, configFileName)
+		_, err := os.Stat(path)
+		if err == nil {
+			return LoadConfig(path)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unable to find config for %s: %s", dir, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			root, _ := filepath.Abs(start)
+			return &Config{root: root}, nil
+		}
+		dir = parent
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %s", path, err)
+	}
+	config := &Config{}
+	err = yaml.Unmarshal(content, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config %s: %s", path, err)
+	}
+	config.Path = path
+	config.root, err = filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// validate fails for broken settings, unknown plugins are only warnings so wrappers with different plugins can share a configuration
+func (c *Config) validate(plugins []*Plugin) ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	known := map[string]bool{}
+	for _, plugin := range builtinPlugins {
+		known[plugin.Name] = true
+	}
+	for _, plugin := range plugins {
+		known[plugin.Name] = true
+	}
+	names := append(append([]string{}, c.Enable...), c.Disable...)
+	for name := range c.Plugins {
+		names = append(names, name)
+	}
+	for tag := range c.Severity {
+		name, _, _ := strings.Cut(tag, "/")
+		names = append(names, name)
+	}
+	warnings := []string{}
+	warned := map[string]bool{}
+	for _, name := range names {
+		if !known[name] && !warned[name] {
+			warned[name] = true
+			warnings = append(warnings, fmt.Sprintf("config %s: unknown plugin %s is ignored", c.Path, name))
+		}
+	}
+	if c.Output != "" && c.Output != "terminal" && c.Output != "csv" && c.Output != "json" && c.Output != "sarif" {
+		return nil, fmt.Errorf("invalid config %s: unknown output format %s", c.Path, c.Output)
+	}
+	return warnings, nil
+}
+
+// pluginEnabled reports whether the plugin runs, built-in plugins are only switched off by disable
+func (c *Config) pluginEnabled(name string) bool {
+	if c == nil {
+		return true
+	}
+	for _, disabled := range c.Disable {
+		if disabled == name {
+			return false
+		}
+	}
+	if len(c.Enable) == 0 {
+		return true
+	}
+	for _, plugin := range builtinPlugins {
+		if plugin.Name == name {
+			return true
+		}
+	}
+	for _, enabled := range c.Enable {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// includesPath reports whether the file or directory should be analyzed.
+// Paths outside of the directory containing the configuration are always included.
+func (c *Config) includesPath(p string, isDir bool) bool {
+	if c == nil || c.root == "" {
+		return true
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(c.root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	if matchAnyGlob(c.Exclude, rel) {
+		return false
+	}
+	if !isDir && len(c.Include) > 0 {
+		return matchAnyGlob(c.Include, rel)
+	}
+	return true
+}
+
+func (c *Config) decodePluginSection(pluginName string, v any) error {
+	if c == nil {
+		return nil
+	}
+	section, found := c.Plugins[pluginName]
+	if !found {
+		return nil
+	}
+	err := section.Decode(v)
+	if err != nil {
+		return fmt.Errorf("invalid config for plugin %s in %s: %s", pluginName, c.Path, err)
+	}
+	return nil
+}
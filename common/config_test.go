@@ -0,0 +1,184 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testPluginConfig struct {
+	Names []string `yaml:"names"`
+	Limit int      `yaml:"limit"`
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	err := os.MkdirAll(nested, 0o755)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	config, err := FindConfig(nested)
+	if err != nil || config.Path != "" {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	content := "plugins:\n  test:\n    names: [foo, bar]\n"
+	err = os.WriteFile(filepath.Join(root, "a", ".check.yaml"), []byte(content), 0o644)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	config, err = FindConfig(nested)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if config.Path != filepath.Join(root, "a", ".check.yaml") {
+		fmt.Printf("config.Path: %s\n", config.Path)
+		t.FailNow()
+	}
+
+	cfg := testPluginConfig{Limit: 3}
+	a := &Analysis{pluginName: "test", config: config}
+	err = a.DecodeConfig(&cfg)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if fmt.Sprint(cfg.Names) != "[foo bar]" || cfg.Limit != 3 {
+		fmt.Printf("cfg: %#v\n", cfg)
+		t.Fail()
+	}
+
+	cfg = testPluginConfig{Limit: 3}
+	a = &Analysis{pluginName: "other", config: config}
+	err = a.DecodeConfig(&cfg)
+	if err != nil || cfg.Names != nil || cfg.Limit != 3 {
+		t.Fail()
+	}
+
+	a = &Analysis{pluginName: "test"}
+	err = a.DecodeConfig(&cfg)
+	if err != nil {
+		t.Fail()
+	}
+
+	var wrongType int
+	a = &Analysis{pluginName: "test", config: config}
+	err = a.DecodeConfig(&wrongType)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestConfigFiltering(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		".check.yaml":           "disable: [second]\ninclude: [\"**/*.go\"]\nexclude: [vendor, \"*_gen.go\"]\n",
+		"main.go":               "package main\n",
+		"main_gen.go":           "package main\n",
+		"main.c":                "int main() {}\n",
+		"vendor/lib/lib.go":     "package lib\n",
+		"internal/pkg/pkg.go":   "package pkg\n",
+		"internal/pkg/gen.go.c": "int x;\n",
+	})
+
+	seen := map[string][]string{}
+	finalized := map[string]bool{}
+	makePlugin := func(name string) *Plugin {
+		return &Plugin{
+			Name:       name,
+			Extensions: []string{"go", "c"},
+			Serial:     true,
+			Run: func(a *Analysis) error {
+				rel, _ := filepath.Rel(root, a.FilePath)
+				seen[name] = append(seen[name], filepath.ToSlash(rel))
+				return nil
+			},
+			Finalize: func(a *Analysis) error {
+				finalized[name] = true
+				return nil
+			},
+		}
+	}
+	plugins := []*Plugin{makePlugin("first"), makePlugin("second")}
+
+	_, err := RunChecksForDirectoriesWithOptions(plugins, []string{root}, Options{Jobs: 1})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if fmt.Sprint(seen["first"]) != "[internal/pkg/pkg.go main.go]" || len(seen["second"]) != 0 {
+		fmt.Printf("seen: %#v\n", seen)
+		t.Fail()
+	}
+	if !finalized["first"] || finalized["second"] {
+		fmt.Printf("finalized: %#v\n", finalized)
+		t.Fail()
+	}
+
+	seen = map[string][]string{}
+	config := &Config{Enable: []string{"second"}}
+	_, err = RunChecksForDirectoriesWithOptions(plugins, []string{root}, Options{Jobs: 1, Config: config})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(seen["first"]) != 0 || len(seen["second"]) != 5 {
+		fmt.Printf("seen: %#v\n", seen)
+		t.Fail()
+	}
+
+	// plugins of other wrappers sharing the configuration are only warned about
+	config = &Config{Path: ".check.yaml", Disable: []string{"third"}, Severity: map[string]Severity{"third/E001": SeverityWarning}}
+	report, err := Run(context.Background(), RunOptions{Options: Options{Config: config}, Plugins: plugins, Paths: []string{root}})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if fmt.Sprint(report.Warnings()) != "[config .check.yaml: unknown plugin third is ignored]" {
+		fmt.Printf("warnings: %v\n", report.Warnings())
+		t.Fail()
+	}
+
+	config = &Config{Output: "xml"}
+	_, err = RunChecksForDirectoriesWithOptions(plugins, []string{root}, Options{Config: config})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestConfigEnableKeepsBuiltinPlugins(t *testing.T) {
+	content := []byte("package foo\n\n// JUSTIFY(functions): nothing to justify\nvar x = 1\n\nfunc a( {}\n")
+	plugin := &Plugin{
+		Name:       "functions",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			return nil
+		},
+	}
+	check := func(config *Config, exp string) {
+		violations, err := RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{Config: config})
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+		tags := []string{}
+		for _, v := range violations {
+			tags = append(tags, v.tag())
+		}
+		if fmt.Sprint(tags) != exp {
+			fmt.Printf("config: %#v\n", config)
+			fmt.Printf("tags: %v\n", tags)
+			t.Fail()
+		}
+	}
+	check(&Config{Enable: []string{"functions"}}, "[syntax/missing justification/unused]")
+	check(&Config{Enable: []string{"functions"}, Disable: []string{"syntax", "justification"}}, "[]")
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTimeout is wrapped by the errors of plugins that exceeded Options.Timeout
+var ErrTimeout = errors.New("plugin timed out")
+
+// PanicError is a recovered panic of a plugin, Stack is the stack trace of the panicking goroutine
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// FileError is a failure while analyzing a file.
+// Plugin is empty if the file couldn't be read or parsed, Path is empty if the plugin failed in Finalize.
+type FileError struct {
+	Path   string
+	Plugin string
+	Err    error
+}
+
+func (e *FileError) Error() string {
+	if e.Plugin == "" {
+		return e.Err.Error()
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("[%s] unable to finalize: %s", e.Plugin, e.Err)
+	}
+	return fmt.Sprintf("[%s] unable to check file %s: %s", e.Plugin, e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// RunError collects the failures of all files and plugins of a run, in the order of the files
+type RunError struct {
+	Errors []*FileError
+}
+
+func (e *RunError) Error() string {
+	lines := []string{}
+	for _, err := range e.Errors {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *RunError) Unwrap() []error {
+	errs := []error{}
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// internalPlugin reports panics and timeouts of plugins as violations, other failures of a run only if Options.KeepGoing is set
+var internalPlugin = &Plugin{
+	Name:       "internal",
+	Doc:        "reports files that couldn't be analyzed and plugins that failed, panicked or timed out",
+	ErrorCodes: []string{"error", "panic", "timeout"},
+}
+
+// isolated failures only affect a single plugin and file, they never stop the run
+func (e *FileError) isolated() bool {
+	var panicErr *PanicError
+	return errors.As(e.Err, &panicErr) || errors.Is(e.Err, ErrTimeout)
+}
+
+func (e *FileError) violation() Violation {
+	code := "error"
+	var panicErr *PanicError
+	if errors.As(e.Err, &panicErr) {
+		code = "panic"
+	} else if errors.Is(e.Err, ErrTimeout) {
+		code = "timeout"
+	}
+	return Violation{
+		PluginName: internalPlugin.Name,
+		FilePath:   e.Path,
+		ErrorCode:  code,
+		Message:    e.Error(),
+		RelContent: []string{},
+	}
+}
@@ -0,0 +1,187 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Location is a range in a file, all these are 0-indexed.
+// A location without a range covers the whole file.
+type Location struct {
+	FilePath string
+
+	StartLine   uint32
+	StartColumn uint32
+	EndLine     uint32
+	EndColumn   uint32
+}
+
+// Fact is something a plugin learned about a file in Run and needs in Finalize, e.g. a declared symbol, an include or an export
+type Fact[T any] struct {
+	Value    T
+	Location Location
+}
+
+// storedFact keeps the value as JSON, so facts of all types can be stored together and cached
+type storedFact struct {
+	Type     string
+	Value    []byte
+	Location Location
+}
+
+// fileFacts are the facts a plugin recorded for one file, there is one for every file the plugin analyzed
+type fileFacts struct {
+	plugin string
+	path   string
+	facts  []storedFact
+}
+
+// finalizeData is what Finalize can look at, the facts of the plugin and the files of the run to report violations in
+type finalizeData struct {
+	files   []fileFacts
+	sources map[string]sourceFile
+	parsed  map[string]*parsedSource
+}
+
+type parsedSource struct {
+	content []byte
+	root    *sitter.Node
+}
+
+func factType[T any]() string {
+	t := reflect.TypeFor[T]()
+	if t.Name() == "" || t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// RecordFact stores a fact about the node for Finalize, a nil node makes it a fact about the whole file.
+// The value is stored as JSON, so only exported fields are kept, in return facts are cached with the violations of the file.
+func RecordFact[T any](a *Analysis, n *sitter.Node, value T) error {
+	if a.Root == nil {
+		return errors.New("facts can only be recorded during Run")
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("unable to record fact: %s", err)
+	}
+	loc := Location{FilePath: a.FilePath}
+	if n != nil {
+		loc.StartLine = n.StartPoint().Row
+		loc.StartColumn = n.StartPoint().Column
+		loc.EndLine = n.EndPoint().Row
+		loc.EndColumn = n.EndPoint().Column
+	}
+	a.facts = append(a.facts, storedFact{Type: factType[T](), Value: data, Location: loc})
+	return nil
+}
+
+// Facts returns the facts of type T the plugin recorded in any file, ordered by file and then by the order they were recorded in
+func Facts[T any](a *Analysis) ([]Fact[T], error) {
+	if a.finalize == nil {
+		return nil, errors.New("facts can only be queried during Finalize")
+	}
+	typ := factType[T]()
+	result := []Fact[T]{}
+	for _, file := range a.finalize.files {
+		for _, f := range file.facts {
+			if f.Type != typ {
+				continue
+			}
+			var value T
+			err := json.Unmarshal(f.Value, &value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode fact of %s: %s", f.Location.FilePath, err)
+			}
+			result = append(result, Fact[T]{Value: value, Location: f.Location})
+		}
+	}
+	return result, nil
+}
+
+// Files lists the files the plugin analyzed in Run or restored from the cache, it's only set during Finalize
+func (a *Analysis) Files() []string {
+	if a.finalize == nil {
+		return nil
+	}
+	result := []string{}
+	for _, file := range a.finalize.files {
+		result = append(result, file.path)
+	}
+	return result
+}
+
+// ReportAt reports a violation at a location in any file, e.g. of a fact during Finalize.
+// The file is read and parsed again, so the violation shows its content and justifications apply as for violations reported in Run.
+func (a *Analysis) ReportAt(loc Location, msg string) {
+	a.ReportAtCode(loc, "", msg)
+}
+
+func (a *Analysis) ReportAtCode(loc Location, errorCode string, msg string) {
+	if loc.StartLine == 0 && loc.StartColumn == 0 && loc.EndLine == 0 && loc.EndColumn == 0 {
+		a.ReportFileCode(loc.FilePath, errorCode, msg)
+		return
+	}
+	var v Violation
+	source := a.source(loc.FilePath)
+	if source == nil {
+		// without the file only the location is known
+		v = newViolation(a.pluginName, loc.FilePath, nil, nil, errorCode, msg)
+	} else {
+		start := sitter.Point{Row: loc.StartLine, Column: loc.StartColumn}
+		end := sitter.Point{Row: loc.EndLine, Column: loc.EndColumn}
+		n := source.root.NamedDescendantForPointRange(start, end)
+		v = newViolation(a.pluginName, loc.FilePath, n, source.content, errorCode, msg)
+	}
+	v.StartLine = loc.StartLine
+	v.StartColumn = loc.StartColumn
+	v.EndLine = loc.EndLine
+	v.EndColumn = loc.EndColumn
+	a.violations = append(a.violations, v)
+}
+
+func (a *Analysis) ReportAtCodef(loc Location, errorCode string, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	a.ReportAtCode(loc, errorCode, msg)
+}
+
+func (a *Analysis) ReportAtf(loc Location, format string, args ...any) {
+	a.ReportAtCodef(loc, "", format, args...)
+}
+
+// source parses a file of the run once per Finalize, it's nil if the file can't be read or parsed
+func (a *Analysis) source(path string) *parsedSource {
+	if a.finalize == nil {
+		return nil
+	}
+	parsed, found := a.finalize.parsed[path]
+	if found {
+		return parsed
+	}
+	a.finalize.parsed[path] = nil
+	file, found := a.finalize.sources[path]
+	if !found {
+		file = newSourceFile(path, nil)
+	}
+	content := file.content
+	if content == nil {
+		var err error
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+	}
+	root, err := parseFileContentContext(a.Context(), content, file.ext)
+	if err != nil {
+		return nil
+	}
+	parsed = &parsedSource{content: content, root: root}
+	a.finalize.parsed[path] = parsed
+	return parsed
+}
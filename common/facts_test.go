@@ -0,0 +1,97 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testDeclaration struct {
+	Name string
+}
+
+func TestFacts(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	writeTestTree(t, src, map[string]string{
+		"a.go": "package foo\n\nfunc a() {}\n\nfunc shared() {}\n",
+		"b.go": "package foo\n\n// JUSTIFY(duplicates/duplicate): kept on purpose\nfunc shared() {}\n",
+		"c.go": "package foo\n\nfunc c() {}\nfunc shared() {}\n",
+	})
+	runs := 0
+	files := []string{}
+	duplicates := &Plugin{
+		Name:       "duplicates",
+		Extensions: []string{"go"},
+		Version:    "1",
+		FactsOnly:  true,
+		Run: func(a *Analysis) error {
+			runs++
+			_, err := Facts[testDeclaration](a)
+			if err == nil {
+				return fmt.Errorf("facts are available in Run")
+			}
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				name := n.ChildByFieldName("name")
+				err := RecordFact(a, name, testDeclaration{Name: name.Content(a.Content)})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Finalize: func(a *Analysis) error {
+			err := RecordFact(a, nil, testDeclaration{})
+			if err == nil {
+				return fmt.Errorf("facts can be recorded in Finalize")
+			}
+			files = a.Files()
+			decls, err := Facts[testDeclaration](a)
+			if err != nil {
+				return err
+			}
+			first := map[string]Location{}
+			for _, decl := range decls {
+				loc, found := first[decl.Value.Name]
+				if !found {
+					first[decl.Value.Name] = decl.Location
+					continue
+				}
+				a.ReportAtCodef(decl.Location, "duplicate", "%s is already declared in %s", decl.Value.Name, filepath.Base(loc.FilePath))
+			}
+			return nil
+		},
+	}
+	opts := RunOptions{Options: Options{Jobs: 1, CacheDir: filepath.Join(root, ".check-cache")}, Plugins: []*Plugin{duplicates}, Paths: []string{src}}
+
+	for i := 0; i < 2; i++ {
+		report, err := Run(context.Background(), opts)
+		if err != nil {
+			fmt.Printf("err: %v\n", err)
+			t.FailNow()
+		}
+		if runs != 3 || len(files) != 3 {
+			fmt.Printf("runs: %d, files: %v\n", runs, files)
+			t.Fail()
+		}
+		act := []string{}
+		for _, v := range report.Violations() {
+			justified := v.Justification != nil
+			act = append(act, fmt.Sprintf("%s %d:%d-%d:%d %s %v %s", filepath.Base(v.FilePath), v.StartLine, v.StartColumn, v.EndLine, v.EndColumn, v.tag(), justified, v.Message))
+		}
+		exp := []string{
+			"b.go 3:5-3:11 duplicates/duplicate true shared is already declared in a.go",
+			"c.go 3:5-3:11 duplicates/duplicate false shared is already declared in a.go",
+		}
+		if strings.Join(act, "\n") != strings.Join(exp, "\n") {
+			fmt.Printf("run %d:\n%s\n", i, strings.Join(act, "\n"))
+			t.Fail()
+		}
+		if len(report.Violations()) == 2 && report.Violations()[1].RelContent[1] != "func shared() {}\n" {
+			fmt.Printf("content: %q\n", report.Violations()[1].RelContent)
+			t.Fail()
+		}
+	}
+}
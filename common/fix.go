@@ -0,0 +1,350 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// number of unchanged lines shown around every change in a diff
+const diffContextLines = 3
+
+// Edit replaces the bytes from StartByte up to EndByte with NewText, an insertion has StartByte == EndByte
+type Edit struct {
+	StartByte uint32
+	EndByte   uint32
+	NewText   string
+
+	// all these are 0-indexed, they are derived from the byte offsets when the violation is reported
+	StartLine   uint32
+	StartColumn uint32
+	EndLine     uint32
+	EndColumn   uint32
+}
+
+// ReplaceNode creates an edit replacing the whole node
+func ReplaceNode(n *sitter.Node, newText string) Edit {
+	return Edit{StartByte: n.StartByte(), EndByte: n.EndByte(), NewText: newText}
+}
+
+func newEdits(content []byte, edits []Edit) []Edit {
+	result := []Edit{}
+	for _, edit := range edits {
+		edit.StartLine, edit.StartColumn = bytePosition(content, edit.StartByte)
+		edit.EndLine, edit.EndColumn = bytePosition(content, edit.EndByte)
+		result = append(result, edit)
+	}
+	return result
+}
+
+func bytePosition(content []byte, offset uint32) (uint32, uint32) {
+	if int(offset) > len(content) {
+		offset = uint32(len(content))
+	}
+	line := uint32(strings.Count(string(content[:offset]), "\n"))
+	column := offset
+	lastNewline := strings.LastIndex(string(content[:offset]), "\n")
+	if lastNewline >= 0 {
+		column = offset - uint32(lastNewline) - 1
+	}
+	return line, column
+}
+
+// FixedFile is the result of applying the fixes of all violations in a single file
+type FixedFile struct {
+	Path     string
+	Original []byte
+	Fixed    []byte
+
+	// Applied are the violations whose fix is part of Fixed
+	Applied []Violation
+
+	// Conflicts are the violations whose fix overlaps with the fix of another violation, they are left untouched
+	Conflicts []Violation
+}
+
+// ComputeFixes applies the fixes of all violations without a justification.
+// The fixes of a single violation are applied all together or not at all, the first violation wins on overlapping edits.
+// read is called once for every file with fixes to get its current content.
+func ComputeFixes(violations []Violation, read func(path string) ([]byte, error)) ([]FixedFile, error) {
+	paths := []string{}
+	byPath := map[string][]Violation{}
+	for _, vio := range violations {
+		if len(vio.Edits) == 0 || vio.Justification != nil || vio.FilePath == "" {
+			continue
+		}
+		_, found := byPath[vio.FilePath]
+		if !found {
+			paths = append(paths, vio.FilePath)
+		}
+		byPath[vio.FilePath] = append(byPath[vio.FilePath], vio)
+	}
+
+	result := []FixedFile{}
+	for _, path := range paths {
+		content, err := read(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fix %s: %s", path, err)
+		}
+		file, err := fixFile(path, content, byPath[path])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+func fixFile(path string, content []byte, violations []Violation) (FixedFile, error) {
+	file := FixedFile{Path: path, Original: content}
+	accepted := []Edit{}
+	for _, vio := range violations {
+		edits := append([]Edit{}, vio.Edits...)
+		sort.SliceStable(edits, func(i, j int) bool {
+			return edits[i].StartByte < edits[j].StartByte
+		})
+		for _, edit := range edits {
+			if edit.StartByte > edit.EndByte || int(edit.EndByte) > len(content) {
+				return FixedFile{}, fmt.Errorf("unable to fix %s: invalid edit from byte %d to %d by plugin %s", path, edit.StartByte, edit.EndByte, vio.PluginName)
+			}
+		}
+		if editsOverlap(edits) || editsOverlap(append(append([]Edit{}, accepted...), edits...)) {
+			file.Conflicts = append(file.Conflicts, vio)
+			continue
+		}
+		accepted = append(accepted, edits...)
+		file.Applied = append(file.Applied, vio)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].StartByte < accepted[j].StartByte
+	})
+	fixed := []byte{}
+	last := uint32(0)
+	for _, edit := range accepted {
+		fixed = append(fixed, content[last:edit.StartByte]...)
+		fixed = append(fixed, edit.NewText...)
+		last = edit.EndByte
+	}
+	fixed = append(fixed, content[last:]...)
+	file.Fixed = fixed
+	return file, nil
+}
+
+// RemainingViolations returns the violations whose fix wasn't applied to any of the files
+func RemainingViolations(violations []Violation, files []FixedFile) []Violation {
+	applied := map[string]int{}
+	for _, file := range files {
+		for _, vio := range file.Applied {
+			applied[vio.fixKey()]++
+		}
+	}
+	result := []Violation{}
+	for _, vio := range violations {
+		key := vio.fixKey()
+		if applied[key] > 0 {
+			applied[key]--
+			continue
+		}
+		result = append(result, vio)
+	}
+	return result
+}
+
+func (v Violation) fixKey() string {
+	return fmt.Sprintf("%s:%d:%d:%d:%d:%s:%s", v.FilePath, v.StartLine, v.StartColumn, v.EndLine, v.EndColumn, v.tag(), v.Message)
+}
+
+// editsOverlap reports whether any two edits touch the same bytes, two insertions at the same position overlap as well since their order would be arbitrary
+func editsOverlap(edits []Edit) bool {
+	for i := 0; i < len(edits); i++ {
+		for j := i + 1; j < len(edits); j++ {
+			a := edits[i]
+			b := edits[j]
+			if a.StartByte == b.StartByte || (a.StartByte < b.EndByte && b.StartByte < a.EndByte) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Write replaces the file on disk atomically, the fixed content is written to a temporary file which is then renamed
+func (f FixedFile) Write() error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return fmt.Errorf("unable to write fixes to %s: %s", f.Path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to write fixes to %s: %s", f.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(f.Fixed)
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write fixes to %s: %s", f.Path, err)
+	}
+	return nil
+}
+
+// Diff returns the changes as a unified diff, it's empty if nothing changed
+func (f FixedFile) Diff() string {
+	if string(f.Original) == string(f.Fixed) {
+		return ""
+	}
+	a := strings.SplitAfter(string(f.Original), "\n")
+	if a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	b := strings.SplitAfter(string(f.Fixed), "\n")
+	if b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+	name := filepath.ToSlash(f.Path)
+	result := fmt.Sprintf("--- a/%s\n+++ b/%s\n", name, name)
+	ops := diffLines(a, b)
+
+	for start := 0; start < len(ops); {
+		// finding the next change and all changes close enough to it to share a hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops) && i <= last+2*diffContextLines; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := max(first-diffContextLines, 0)
+		to := min(last+diffContextLines+1, len(ops))
+
+		countA := 0
+		countB := 0
+		lines := ""
+		for _, op := range ops[from:to] {
+			line := ""
+			if op.kind == '+' {
+				line = b[op.b]
+				countB++
+			} else {
+				line = a[op.a]
+				countA++
+				if op.kind == ' ' {
+					countB++
+				}
+			}
+			lines += string(op.kind) + line
+			if !strings.HasSuffix(line, "\n") {
+				lines += "\n\\ No newline at end of file\n"
+			}
+		}
+		startA := ops[from].a
+		if countA > 0 {
+			startA++
+		}
+		startB := ops[from].b
+		if countB > 0 {
+			startB++
+		}
+		result += fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB) + lines
+		start = to
+	}
+	return result
+}
+
+type diffOp struct {
+	// ' ' for an unchanged line, '-' for a deleted one and '+' for an inserted one
+	kind byte
+
+	// positions in the old and new lines before this operation
+	a int
+	b int
+}
+
+// diffLines computes the shortest edit script with the algorithm from "An O(ND) Difference Algorithm and Its Variations" by Eugene W. Myers
+func diffLines(a []string, b []string) []diffOp {
+	n := len(a)
+	m := len(b)
+	offset := n + m
+	v := make([]int, 2*(n+m)+2)
+	trace := [][]int{}
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// walking back through the trace yields the operations in reverse
+	reversed := []diffOp{}
+	x := n
+	y := m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffOp{' ', x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffOp{'+', prevX, prevY})
+			} else {
+				reversed = append(reversed, diffOp{'-', prevX, prevY})
+			}
+		}
+		x = prevX
+		y = prevY
+	}
+
+	ops := []diffOp{}
+	for i := len(reversed) - 1; i >= 0; i-- {
+		ops = append(ops, reversed[i])
+	}
+	return ops
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var renamePlugin = &Plugin{
+	Name:       "rename",
+	Extensions: []string{"go"},
+	Run: func(a *Analysis) error {
+		for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+			name := n.ChildByFieldName("name")
+			switch name.Content(a.Content) {
+			case "old":
+				a.ReportCodeWithFix(name, "R001", "old is deprecated", ReplaceNode(name, "renamed"))
+			case "conflicting":
+				// overlapping with the previous fix of the same function
+				a.ReportWithFix(name, "conflicting is deprecated", ReplaceNode(name, "conflicting1"))
+				a.ReportWithFix(n, "conflicting is deprecated", ReplaceNode(n, "func conflicting2() {}"))
+			case "justified":
+				a.ReportWithFix(n, "justified is deprecated", ReplaceNode(name, "unjustified"))
+			case "noFix":
+				a.Report(name, "noFix is deprecated")
+			}
+		}
+		return nil
+	},
+}
+
+func TestComputeFixes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.go")
+	content := "package foo\n\nfunc old() {}\n\nfunc conflicting() {}\n\n// JUSTIFY(rename): still used\nfunc justified() {}\n\nfunc noFix() {}\n"
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	violations, err := RunChecksForFiles([]*Plugin{renamePlugin}, []string{path}, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(violations) != 5 {
+		fmt.Printf("violations: %v\n", violations)
+		t.FailNow()
+	}
+	edit := violations[0].Edits[0]
+	if edit.StartLine != 2 || edit.StartColumn != 5 || edit.EndLine != 2 || edit.EndColumn != 8 {
+		fmt.Printf("edit: %#v\n", edit)
+		t.Fail()
+	}
+
+	data, err := json.Marshal(violations[0])
+	jsonExp := `{"code":"R001","data":{"edits":[{"newText":"renamed","range":{"end":{"character":8,"line":2},"start":{"character":5,"line":2}}}]},"message":"old is deprecated","range":{"end":{"character":8,"line":2},"start":{"character":5,"line":2}},"severity":1,"source":"rename"}`
+	if err != nil || string(data) != jsonExp {
+		fmt.Printf("jsonExp: %v\n", jsonExp)
+		fmt.Printf("string(data): %v\n", string(data))
+		t.Fail()
+	}
+
+	files, err := ComputeFixes(violations, os.ReadFile)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(files) != 1 || len(files[0].Applied) != 2 || len(files[0].Conflicts) != 1 {
+		fmt.Printf("files: %#v\n", files)
+		t.FailNow()
+	}
+	if files[0].Conflicts[0].StartLine != 4 || files[0].Conflicts[0].StartColumn != 0 {
+		fmt.Printf("files[0].Conflicts[0]: %v\n", files[0].Conflicts[0])
+		t.Fail()
+	}
+
+	fixed := "package foo\n\nfunc renamed() {}\n\nfunc conflicting1() {}\n\n// JUSTIFY(rename): still used\nfunc justified() {}\n\nfunc noFix() {}\n"
+	if string(files[0].Fixed) != fixed {
+		fmt.Printf("files[0].Fixed: %s\n", files[0].Fixed)
+		t.Fail()
+	}
+
+	diff := "--- a/" + filepath.ToSlash(path) + "\n+++ b/" + filepath.ToSlash(path) + "\n" +
+		"@@ -1,8 +1,8 @@\n package foo\n \n-func old() {}\n+func renamed() {}\n \n-func conflicting() {}\n+func conflicting1() {}\n \n // JUSTIFY(rename): still used\n func justified() {}\n"
+	if files[0].Diff() != diff {
+		fmt.Printf("diff: %s\n", diff)
+		fmt.Printf("files[0].Diff(): %s\n", files[0].Diff())
+		t.Fail()
+	}
+
+	remaining := RemainingViolations(violations, files)
+	messages := []string{}
+	for _, v := range remaining {
+		messages = append(messages, v.Message)
+	}
+	if fmt.Sprint(messages) != "[conflicting is deprecated justified is deprecated noFix is deprecated]" {
+		fmt.Printf("messages: %#v\n", messages)
+		t.Fail()
+	}
+
+	err = files[0].Write()
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	written, err := os.ReadFile(path)
+	if err != nil || string(written) != fixed {
+		fmt.Printf("written: %s\n", written)
+		t.Fail()
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		fmt.Printf("entries: %v\n", entries)
+		t.Fail()
+	}
+}
+
+func TestComputeFixesInvalidEdit(t *testing.T) {
+	v := Violation{PluginName: "broken", FilePath: "a.go", Edits: []Edit{{StartByte: 3, EndByte: 20}}}
+	_, err := ComputeFixes([]Violation{v}, func(path string) ([]byte, error) {
+		return []byte("package foo\n"), nil
+	})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func checkDiff(t *testing.T, original string, fixed string, exp string) {
+	diff := FixedFile{Path: "a.txt", Original: []byte(original), Fixed: []byte(fixed)}.Diff()
+	if diff != exp {
+		fmt.Printf("exp: %s\n", exp)
+		fmt.Printf("diff: %s\n", diff)
+		t.Fail()
+	}
+}
+
+func TestDiff(t *testing.T) {
+	header := "--- a/a.txt\n+++ b/a.txt\n"
+	checkDiff(t, "a\n", "a\n", "")
+	checkDiff(t, "", "a\n", header+"@@ -0,0 +1,1 @@\n+a\n")
+	checkDiff(t, "a\n", "", header+"@@ -1,1 +0,0 @@\n-a\n")
+	checkDiff(t, "a\nb", "a\nc", header+"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n")
+	checkDiff(t, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "1\nx\n3\n4\n5\n6\n7\n8\n9\n10\ny\n12\n",
+		header+"@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n+y\n 12\n")
+	checkDiff(t, "1\n2\n3\n4\n5\n6\n7\n8\n", "1\nx\n3\n4\n5\n6\ny\n8\n",
+		header+"@@ -1,8 +1,8 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n-7\n+y\n 8\n")
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob matches a slash separated path against a glob pattern.
+// Besides the syntax of path.Match a "**" segment matches any number of segments, including none.
+// A pattern without a slash matches the last segment of the path only, e.g. "*.pb.go" matches files in every directory.
+func matchGlob(pattern string, p string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(p))
+		return matched
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		matched, _ := path.Match(pattern[0], segments[0])
+		if !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
+
+func matchAnyGlob(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
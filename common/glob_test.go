@@ -0,0 +1,39 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		exp     bool
+	}{
+		{"vendor", "vendor", true},
+		{"vendor", "a/vendor", true},
+		{"vendor", "vendor/a.go", false},
+		{"*.pb.go", "api/v1/foo.pb.go", true},
+		{"*.pb.go", "api/v1/foo.go", false},
+		{"/gen", "gen", true},
+		{"gen/", "gen", true},
+		{"gen/*.go", "gen/a.go", true},
+		{"gen/*.go", "gen/sub/a.go", false},
+		{"gen/**", "gen", true},
+		{"gen/**", "gen/sub/a.go", true},
+		{"**/testdata", "a/b/testdata", true},
+		{"**/testdata", "testdata", true},
+		{"src/**/*.go", "src/a.go", true},
+		{"src/**/*.go", "src/a/b/c.go", true},
+		{"src/**/*.go", "lib/a.go", false},
+		{"src/a.go", "src/a.go/b", false},
+	}
+	for _, c := range cases {
+		act := matchGlob(c.pattern, c.path)
+		if act != c.exp {
+			fmt.Printf("matchGlob(%#v, %#v): exp %v, act %v\n", c.pattern, c.path, c.exp, act)
+			t.Fail()
+		}
+	}
+}
@@ -2,4 +2,8 @@ module github.com/unnamedtiger/check/common
 
 go 1.22.4
 
-require github.com/smacker/go-tree-sitter v0.0.0-20240625050157-a31a98a7c0f6
+require (
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/smacker/go-tree-sitter v0.0.0-20240625050157-a31a98a7c0f6
+	gopkg.in/yaml.v3 v3.0.1
+)
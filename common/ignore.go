@@ -0,0 +1,179 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// files with patterns of paths to skip, both follow the syntax of .gitignore, .checkignore takes precedence
+var ignoreFileNames = []string{".gitignore", ".checkignore"}
+
+// dependency directories are skipped like ignored ones, a negated ignore rule or an include glob naming them brings them back
+var dependencyDirNames = []string{"vendor", "node_modules"}
+
+// NOTE: this follows https://go.dev/s/generatedcode but accepts any comment syntax
+var generatedRegex = regexp.MustCompile(`(?m)^\W*Code generated .* DO NOT EDIT`)
+
+// only the beginning of a file is searched for the generated header
+const generatedHeaderSize = 4096
+
+type ignoreRule struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+// ignoreMatcher applies the ignore files of a directory tree, all paths are absolute
+type ignoreMatcher struct {
+	top   string
+	rules map[string][]ignoreRule
+}
+
+func parseIgnoreRules(content []byte) []ignoreRule {
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.segments = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	if !r.anchored {
+		segments = segments[len(segments)-1:]
+	}
+	return matchSegments(r.segments, segments)
+}
+
+// newIgnoreMatcher also applies the ignore files of the parent directories up to the root of the git repository
+func newIgnoreMatcher(dir string) (*ignoreMatcher, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	top := dir
+	for candidate := dir; ; {
+		_, err := os.Stat(filepath.Join(candidate, ".git"))
+		if err == nil {
+			top = candidate
+			break
+		}
+		parent := filepath.Dir(candidate)
+		if parent == candidate {
+			break
+		}
+		candidate = parent
+	}
+	return &ignoreMatcher{top: top, rules: map[string][]ignoreRule{}}, nil
+}
+
+func (m *ignoreMatcher) rulesForDir(dir string) ([]ignoreRule, error) {
+	rules, found := m.rules[dir]
+	if found {
+		return rules, nil
+	}
+	rules = []ignoreRule{}
+	for _, name := range ignoreFileNames {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ignore file: %s", err)
+		}
+		rules = append(rules, parseIgnoreRules(content)...)
+	}
+	m.rules[dir] = rules
+	return rules, nil
+}
+
+// includesDependencyDir reports whether one of the include globs names the dependency directory, e.g. vendor/**
+func includesDependencyDir(globs []string, path string) bool {
+	name := filepath.Base(path)
+	if !containsString(dependencyDirNames, name) {
+		return false
+	}
+	for _, glob := range globs {
+		if containsString(strings.Split(glob, "/"), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ignoreMatcher) ignored(path string, isDir bool) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	if isDir && filepath.Base(path) == ".git" {
+		return true, nil
+	}
+
+	dirs := []string{}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+		if dir == m.top || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	ignored := isDir && containsString(dependencyDirNames, filepath.Base(path))
+	for _, dir := range dirs {
+		rules, err := m.rulesForDir(dir)
+		if err != nil {
+			return false, err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false, err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range rules {
+			if rule.matches(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored, nil
+}
+
+func isGenerated(content []byte) bool {
+	if len(content) > generatedHeaderSize {
+		content = content[:generatedHeaderSize]
+	}
+	return generatedRegex.Match(content)
+}
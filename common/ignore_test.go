@@ -0,0 +1,192 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0o644)
+		}
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		".git/HEAD":              "ref: refs/heads/main\n",
+		".gitignore":             "# comment\n\n*.log\n!keep.log\nbuild/\n/top.go\ndocs/**/*.md\n",
+		"sub/.checkignore":       "local.go\n!build/\n",
+		"sub/.gitignore":         "other.go\n",
+		"src/top.go":             "",
+		"top.go":                 "",
+		"sub/local.go":           "",
+		"sub/other.go":           "",
+		"sub/build/x.go":         "",
+		"docs/a/b/readme.md":     "",
+		"docs/readme.txt":        "",
+		"app.log":                "",
+		"keep.log":               "",
+		"build/out.go":           "",
+		"sub/nested/app.log":     "",
+		"sub/nested/fine.go":     "",
+		"sub/nested/not/a/build": "",
+	})
+
+	m, err := newIgnoreMatcher(filepath.Join(root, "sub"))
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if m.top != root {
+		fmt.Printf("m.top: %s\n", m.top)
+		t.FailNow()
+	}
+
+	cases := []struct {
+		path  string
+		isDir bool
+		exp   bool
+	}{
+		{".git", true, true},
+		{"top.go", false, true},
+		{"src/top.go", false, false},
+		{"sub/local.go", false, true},
+		{"sub/other.go", false, true},
+		{"sub/build", true, false},
+		{"build", true, true},
+		{"docs/a/b/readme.md", false, true},
+		{"docs/readme.txt", false, false},
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/nested/app.log", false, true},
+		{"sub/nested/fine.go", false, false},
+		{"sub/nested/not/a/build", false, false},
+	}
+	for _, c := range cases {
+		act, err := m.ignored(filepath.Join(root, filepath.FromSlash(c.path)), c.isDir)
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+		if act != c.exp {
+			fmt.Printf("ignored(%#v): exp %v, act %v\n", c.path, c.exp, act)
+			t.Fail()
+		}
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	cases := []struct {
+		code string
+		exp  bool
+	}{
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage foo\n", true},
+		{"// Copyright 2024\n\n// Code generated by stringer; DO NOT EDIT.\npackage foo\n", true},
+		{"# Code generated by a script. DO NOT EDIT.\nimport os\n", true},
+		{"/* Code generated by hand. DO NOT EDIT. */\nint x;\n", true},
+		{"package foo\n\n// This was not Code generated at all. DO NOT EDIT.\n", false},
+		{"package foo\n", false},
+	}
+	for _, c := range cases {
+		if isGenerated([]byte(c.code)) != c.exp {
+			fmt.Printf("isGenerated(%#v): exp %v\n", c.code, c.exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestRunChecksFiltering(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		".gitignore":          "ignored/\n",
+		"main.go":             "package main\n",
+		"main_test.go":        "package main\n",
+		"gen.go":              "// Code generated by a tool. DO NOT EDIT.\n\npackage main\n",
+		"ignored/ignored.go":  "package ignored\n",
+		"vendor/lib/lib.go":   "package lib\n",
+		"node_modules/x/x.go": "package x\n",
+		"internal/pkg/pkg.go": "package pkg\n",
+	})
+
+	seen := []string{}
+	plugin := &Plugin{
+		Name:       "test",
+		Extensions: []string{"go"},
+		Serial:     true,
+		Run: func(a *Analysis) error {
+			rel, _ := filepath.Rel(root, a.FilePath)
+			seen = append(seen, filepath.ToSlash(rel))
+			return nil
+		},
+	}
+	check := func(opts Options, exp string) {
+		seen = []string{}
+		opts.Jobs = 1
+		_, err := RunChecksForDirectoriesWithOptions([]*Plugin{plugin}, []string{root}, opts)
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+		if fmt.Sprint(seen) != exp {
+			fmt.Printf("opts: %#v\n", opts)
+			fmt.Printf("exp: %s\n", exp)
+			fmt.Printf("act: %v\n", seen)
+			t.Fail()
+		}
+	}
+	check(Options{}, "[internal/pkg/pkg.go main.go main_test.go]")
+	check(Options{IncludeGenerated: true}, "[gen.go internal/pkg/pkg.go main.go main_test.go]")
+	check(Options{NoIgnoreFiles: true}, "[ignored/ignored.go internal/pkg/pkg.go main.go main_test.go node_modules/x/x.go vendor/lib/lib.go]")
+	check(Options{Exclude: []string{"*_test.go"}}, "[internal/pkg/pkg.go main.go]")
+	check(Options{Include: []string{"internal/**"}}, "[internal/pkg/pkg.go]")
+	check(Options{Include: []string{"vendor/**"}}, "[vendor/lib/lib.go]")
+
+	// dependency directories come back with a negated ignore rule
+	writeTestTree(t, root, map[string]string{".checkignore": "!node_modules/\n"})
+	check(Options{}, "[internal/pkg/pkg.go main.go main_test.go node_modules/x/x.go]")
+}
+
+func TestRunChecksExplicitGeneratedFile(t *testing.T) {
+	root := t.TempDir()
+	content := "// Code generated by a tool. DO NOT EDIT.\n\npackage main\n\nfunc main() {}\n"
+	writeTestTree(t, root, map[string]string{"gen.go": content})
+	path := filepath.Join(root, "gen.go")
+	plugin := &Plugin{
+		Name:       "functions",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				a.Report(n, "function")
+			}
+			return nil
+		},
+	}
+
+	// the header only skips files found in a directory
+	violations, err := RunChecksForFiles([]*Plugin{plugin}, []string{path}, Options{})
+	if err != nil || len(violations) != 1 || violations[0].tag() != "functions" {
+		fmt.Printf("file: %v %v\n", err, violations)
+		t.Fail()
+	}
+	violations, err = RunChecksForContent([]*Plugin{plugin}, path, []byte(content), Options{})
+	if err != nil || len(violations) != 1 || violations[0].tag() != "functions" {
+		fmt.Printf("content: %v %v\n", err, violations)
+		t.Fail()
+	}
+	violations, err = RunChecksForDirectories([]*Plugin{plugin}, []string{root})
+	if err != nil || len(violations) != 0 {
+		fmt.Printf("directory: %v %v\n", err, violations)
+		t.Fail()
+	}
+}
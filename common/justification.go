@@ -1,7 +1,10 @@
 package common
 
 import (
+	"bytes"
+	"fmt"
 	"strings"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
 )
@@ -15,24 +18,146 @@ type Justification struct {
 
 	Tag     string
 	Message string
+
+	// optional fields, e.g. JUSTIFY(tag; until=2026-12-31; ticket=ABC-123): message
+	Ticket string
+	Until  time.Time
+
+	// Scope tells which violations the justification covers, see the Scope constants
+	Scope string
+}
+
+const (
+	// ScopeNode justifications are placed directly before the violating node or in a trailing comment on its last line
+	ScopeNode = "node"
+	// ScopeEnclosing justifications are placed before a node enclosing the violating node, e.g. a function declaration
+	ScopeEnclosing = "enclosing"
+	// ScopeRegion justifications cover everything between JUSTIFY-BEGIN and JUSTIFY-END
+	ScopeRegion = "region"
+	// ScopeFile justifications cover the whole file, they are placed before any code with JUSTIFY-FILE
+	ScopeFile = "file"
+
+	// scopeEnd marks a JUSTIFY-END, it's never returned as a justification
+	scopeEnd = "end"
+)
+
+// justificationDirectives are the prefixes starting a justification in a comment
+var justificationDirectives = []struct {
+	prefix string
+	scope  string
+}{
+	{"JUSTIFY(", ScopeNode},
+	{"JUSTIFY-FILE(", ScopeFile},
+	{"JUSTIFY-BEGIN(", ScopeRegion},
+	{"JUSTIFY-END", scopeEnd},
+}
+
+// dates in justifications are given without time and zone
+const justificationDateFormat = "2006-01-02"
+
+// now is replaced in tests, the date decides whether justifications are expired
+var now = time.Now
+
+// Expired reports whether the justification has an until date before the day of t, it's still valid on that date itself
+func (j Justification) Expired(t time.Time) bool {
+	return !j.Until.IsZero() && t.Format(justificationDateFormat) > j.Until.Format(justificationDateFormat)
+}
+
+// details lists the optional fields for humans, it's empty if there are none
+func (j Justification) details() string {
+	details := []string{}
+	if j.Ticket != "" {
+		details = append(details, "ticket "+j.Ticket)
+	}
+	if !j.Until.IsZero() {
+		details = append(details, "until "+j.Until.Format(justificationDateFormat))
+	}
+	return strings.Join(details, ", ")
 }
 
+// justificationKey identifies a justification independent of the scope it matched with
+type justificationKey struct {
+	line   uint32
+	column uint32
+	tag    string
+}
+
+func (j Justification) key() justificationKey {
+	return justificationKey{j.StartLine, j.StartColumn, j.Tag}
+}
+
+// justificationPlugin is the built-in checker for justifications that don't justify anything.
+// It runs as part of every analyzed file and can be disabled in the configuration like any other plugin.
+var justificationPlugin = &Plugin{
+	Name: "justification",
+	Doc:  "reports unused and invalid justifications and justifications for unknown plugins or error codes",
+}
+
+// findJustification looks for a justification with the tag, the most specific scope wins:
+// comments directly before or trailing the node, before an enclosing node, regions and finally the whole file
 func findJustification(n *sitter.Node, content []byte, tag string) *Justification {
+	just := findJustificationBefore(n, content, tag)
+	if just != nil {
+		return just
+	}
+	root := n
+	for root.Parent() != nil {
+		root = root.Parent()
+	}
+	just = findJustificationTrailing(n, root, content, tag)
+	if just != nil {
+		return just
+	}
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		just = findJustificationBefore(p, content, tag)
+		if just != nil {
+			break
+		}
+	}
+	if just != nil {
+		just.Scope = ScopeEnclosing
+		return just
+	}
+
+	if !bytes.Contains(content, []byte("JUSTIFY-")) {
+		return nil
+	}
+	row := n.StartPoint().Row
+	var file *Justification
+	for _, p := range collectJustifications(root, content) {
+		if p.Tag != tag {
+			continue
+		}
+		if p.Scope == ScopeRegion && p.StartLine < row && row < p.regionEnd {
+			return &p.Justification
+		}
+		if p.Scope == ScopeFile && file == nil {
+			file = &p.Justification
+		}
+	}
+	return file
+}
+
+func findJustificationBefore(n *sitter.Node, content []byte, tag string) *Justification {
 	for {
 		n = n.PrevNamedSibling()
 		if n == nil {
 			break
 		}
-		if n.Type() != "comment" {
+		if !isComment(n) {
 			break
 		}
+		if isTrailingComment(n, content) {
+			// it belongs to the code before it on the same line
+			continue
+		}
 
 		text := n.Content(content)
 		startLine := n.StartPoint().Row
 		startColumn := n.StartPoint().Column
 		justifications := ExtractJustifications(text, startLine, startColumn)
 		for _, j := range justifications {
-			if j.Tag == tag {
+			if j.Tag == tag && j.Scope == ScopeNode {
 				return &j
 			}
 		}
@@ -40,13 +165,249 @@ func findJustification(n *sitter.Node, content []byte, tag string) *Justificatio
 	return nil
 }
 
+// findJustificationTrailing looks for a justification in a comment following the node on its last line, e.g. after an import.
+// Grammars attach these comments differently, so all comments starting on that line after the node are considered.
+func findJustificationTrailing(n *sitter.Node, root *sitter.Node, content []byte, tag string) *Justification {
+	end := codeEnd(n, content)
+	row := end.EndPoint().Row
+	for _, c := range findCommentsOnRow(root, row) {
+		if c.StartByte() < end.EndByte() {
+			continue
+		}
+		justifications := ExtractJustifications(c.Content(content), c.StartPoint().Row, c.StartPoint().Column)
+		for _, j := range justifications {
+			if j.Tag == tag && j.Scope == ScopeNode && j.StartLine == row {
+				return &j
+			}
+		}
+	}
+	return nil
+}
+
+// codeEnd finds the last node of the code, some grammars put trailing comments and the line break inside the node, e.g. for #include
+func codeEnd(n *sitter.Node, content []byte) *sitter.Node {
+	for i := int(n.ChildCount()) - 1; i >= 0; i-- {
+		child := n.Child(i)
+		if isComment(child) || len(bytes.TrimSpace([]byte(child.Content(content)))) == 0 {
+			continue
+		}
+		return codeEnd(child, content)
+	}
+	return n
+}
+
+// isTrailingComment reports whether the comment starts on the line code before it ends
+func isTrailingComment(n *sitter.Node, content []byte) bool {
+	prev := n.PrevNamedSibling()
+	return prev != nil && !isComment(prev) && codeEnd(prev, content).EndPoint().Row == n.StartPoint().Row
+}
+
+// findCommentsOnRow only descends into nodes spanning the row
+func findCommentsOnRow(n *sitter.Node, row uint32) []*sitter.Node {
+	if isComment(n) {
+		if n.StartPoint().Row == row {
+			return []*sitter.Node{n}
+		}
+		return nil
+	}
+	comments := []*sitter.Node{}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		if child.StartPoint().Row <= row && row <= child.EndPoint().Row {
+			comments = append(comments, findCommentsOnRow(child, row)...)
+		}
+	}
+	return comments
+}
+
+// collectJustifications finds the justifications in all comments of the file and checks where they are placed.
+// Regions end at the matching JUSTIFY-END, regions without one are invalid and extend to the end of the file.
+func collectJustifications(root *sitter.Node, content []byte) []parsedJustification {
+	// file justifications have to be placed before the first line of code
+	code := root.EndPoint().Row + 1
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		if !isComment(child) {
+			code = child.StartPoint().Row
+			break
+		}
+	}
+
+	result := []parsedJustification{}
+	open := [][]int{}
+	for _, n := range findComments(root) {
+		justifications := parseJustifications(n.Content(content), n.StartPoint().Row, n.StartPoint().Column)
+		begin := []int{}
+		for _, p := range justifications {
+			p.comment = n
+			switch p.Scope {
+			case scopeEnd:
+				if len(open) > 0 {
+					for _, i := range open[len(open)-1] {
+						result[i].regionEnd = p.StartLine
+					}
+					open = open[:len(open)-1]
+					continue
+				}
+				// kept only to be reported, it has no tag so it never justifies anything
+				p.problem = "JUSTIFY-END without JUSTIFY-BEGIN"
+			case ScopeRegion:
+				begin = append(begin, len(result))
+			case ScopeFile:
+				if p.StartLine >= code {
+					p.problem = "JUSTIFY-FILE has to be placed before any code"
+				}
+			}
+			result = append(result, p)
+		}
+		if len(begin) > 0 {
+			open = append(open, begin)
+		}
+	}
+	for _, begin := range open {
+		for _, i := range begin {
+			result[i].regionEnd = root.EndPoint().Row + 1
+			if result[i].problem == "" {
+				result[i].problem = "JUSTIFY-BEGIN without JUSTIFY-END"
+			}
+		}
+	}
+	return result
+}
+
+// checkJustifications reports the justifications in the file that aren't matched by any violation of the plugins that ran.
+// Justifications for plugins that didn't run, e.g. because they are disabled, are only checked for typos.
+func checkJustifications(plugins []*Plugin, ran []*Plugin, path string, content []byte, root *sitter.Node, violations []Violation) []Violation {
+	if !bytes.Contains(content, []byte("JUSTIFY")) {
+		return nil
+	}
+	known := map[string]*Plugin{}
+	for _, plugin := range builtinPlugins {
+		known[plugin.Name] = plugin
+	}
+	for _, plugin := range plugins {
+		known[plugin.Name] = plugin
+	}
+	checked := map[string]bool{}
+	for _, plugin := range ran {
+		checked[plugin.Name] = true
+	}
+	used := map[justificationKey]bool{}
+	for _, vio := range violations {
+		if vio.Justification != nil {
+			used[vio.Justification.key()] = true
+		}
+		if vio.expiredJustification != nil {
+			used[vio.expiredJustification.key()] = true
+		}
+	}
+
+	result := []Violation{}
+	for _, j := range collectJustifications(root, content) {
+		name, code, _ := strings.Cut(j.Tag, "/")
+		plugin, found := known[name]
+		errorCode := ""
+		msg := ""
+		if j.Scope == scopeEnd {
+			errorCode = "invalid"
+			msg = fmt.Sprintf("invalid justification: %s", j.problem)
+		} else if !found {
+			errorCode = "unknown-plugin"
+			msg = fmt.Sprintf("justification for unknown plugin %s", name)
+		} else if code != "" && len(plugin.ErrorCodes) > 0 && !containsString(plugin.ErrorCodes, code) {
+			errorCode = "unknown-code"
+			msg = fmt.Sprintf("justification for unknown error code %s of plugin %s", code, name)
+		} else if j.problem != "" {
+			errorCode = "invalid"
+			msg = fmt.Sprintf("invalid justification for %s: %s", j.Tag, j.problem)
+		} else if checked[name] && !used[j.key()] {
+			errorCode = "unused"
+			msg = fmt.Sprintf("justification for %s doesn't justify any violation", j.Tag)
+		} else {
+			continue
+		}
+		v := newViolation(justificationPlugin.Name, path, j.comment, content, errorCode, msg)
+		v.StartLine = j.StartLine
+		v.StartColumn = j.StartColumn
+		v.EndLine = j.EndLine
+		v.EndColumn = j.EndColumn
+		if errorCode == "unused" {
+			just := j.Justification
+			v.unusedJustification = &just
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+func findComments(n *sitter.Node) []*sitter.Node {
+	if isComment(n) {
+		return []*sitter.Node{n}
+	}
+	comments := []*sitter.Node{}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		comments = append(comments, findComments(n.NamedChild(i))...)
+	}
+	return comments
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NOTE: most grammars use "comment", some distinguish "line_comment" and "block_comment"
+func isComment(n *sitter.Node) bool {
+	return strings.HasSuffix(n.Type(), "comment")
+}
+
 func ExtractJustifications(text string, startLine uint32, startColumn uint32) []Justification {
 	justifications := []Justification{}
+	for _, p := range parseJustifications(text, startLine, startColumn) {
+		if p.Scope != scopeEnd {
+			justifications = append(justifications, p.Justification)
+		}
+	}
+	return justifications
+}
+
+type parsedJustification struct {
+	Justification
+
+	// problem describes an invalid optional field or placement, the justification is still used without it
+	problem string
+
+	// the comment containing the justification and for regions the line of the matching JUSTIFY-END
+	comment   *sitter.Node
+	regionEnd uint32
+}
+
+func parseJustifications(text string, startLine uint32, startColumn uint32) []parsedJustification {
+	justifications := []parsedJustification{}
 
 	for len(text) > 0 {
-		if strings.HasPrefix(text, "JUSTIFY(") {
+		for _, directive := range justificationDirectives {
+			if !strings.HasPrefix(text, directive.prefix) {
+				continue
+			}
 			beginLen := len(text)
-			text = strings.TrimPrefix(text, "JUSTIFY(")
+			text = strings.TrimPrefix(text, directive.prefix)
+			if directive.scope == scopeEnd {
+				j := Justification{
+					StartLine:   startLine,
+					StartColumn: startColumn,
+					EndLine:     startLine,
+					EndColumn:   startColumn + uint32(beginLen-len(text)),
+					Scope:       scopeEnd,
+				}
+				justifications = append(justifications, parsedJustification{Justification: j})
+				startColumn += uint32(beginLen - len(text))
+				break
+			}
 			idx := strings.Index(text, ")")
 			if idx >= 0 {
 				tags := text[:idx]
@@ -62,7 +423,10 @@ func ExtractJustifications(text string, startLine uint32, startColumn uint32) []
 					msg = strings.TrimSpace(text)
 					text = ""
 				}
-				if len(msg) > 0 {
+				// regions are explained at their beginning, but the message is optional as the region itself is visible
+				if len(msg) > 0 || directive.scope == ScopeRegion {
+					tags, fields, _ := strings.Cut(tags, ";")
+					ticket, until, problem := parseJustificationFields(fields)
 					parts := strings.Split(tags, ",")
 					for _, p := range parts {
 						p = strings.TrimSpace(p)
@@ -74,14 +438,18 @@ func ExtractJustifications(text string, startLine uint32, startColumn uint32) []
 								EndColumn:   startColumn + uint32(beginLen-len(text)),
 								Tag:         p,
 								Message:     msg,
+								Ticket:      ticket,
+								Until:       until,
+								Scope:       directive.scope,
 							}
-							justifications = append(justifications, j)
+							justifications = append(justifications, parsedJustification{Justification: j, problem: problem})
 						}
 					}
 				}
 			} else {
-				startColumn += 8 // len("JUSTIFY(")
+				startColumn += uint32(len(directive.prefix))
 			}
+			break
 		}
 
 		if len(text) == 0 {
@@ -99,3 +467,36 @@ func ExtractJustifications(text string, startLine uint32, startColumn uint32) []
 
 	return justifications
 }
+
+func parseJustificationFields(fields string) (string, time.Time, string) {
+	ticket := ""
+	until := time.Time{}
+	problem := ""
+	for _, field := range strings.Split(fields, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, found := strings.Cut(field, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !found {
+			problem = fmt.Sprintf("field %s has no value", key)
+			continue
+		}
+		switch key {
+		case "ticket":
+			ticket = value
+		case "until":
+			date, err := time.Parse(justificationDateFormat, value)
+			if err != nil {
+				problem = fmt.Sprintf("until has to be a date like 2006-01-02: %s", value)
+				continue
+			}
+			until = date
+		default:
+			problem = fmt.Sprintf("unknown field %s", key)
+		}
+	}
+	return ticket, until, problem
+}
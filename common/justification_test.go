@@ -2,7 +2,9 @@ package common
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func assertJustification(t *testing.T, exp Justification, act Justification) {
@@ -24,6 +26,9 @@ func assertJustification(t *testing.T, exp Justification, act Justification) {
 	if exp.Message != act.Message {
 		t.Fail()
 	}
+	if exp.Scope != act.Scope {
+		t.Fail()
+	}
 	if t.Failed() {
 		fmt.Printf("exp: %#v\n", exp)
 		fmt.Printf("act: %#v\n", act)
@@ -32,12 +37,16 @@ func assertJustification(t *testing.T, exp Justification, act Justification) {
 }
 
 func checkForJustifications(t *testing.T, code string, tag string, exp *Justification) {
+	checkForJustificationsInLanguage(t, code, "go", "function_declaration", tag, exp)
+}
+
+func checkForJustificationsInLanguage(t *testing.T, code string, ext string, nodeType string, tag string, exp *Justification) {
 	content := []byte(code)
-	root, err := parseFileContent(content, "go")
+	root, err := parseFileContent(content, ext)
 	if err != nil {
 		t.Fail()
 	}
-	nodes := FindNamedNodes(root, "function_declaration")
+	nodes := FindNamedNodes(root, nodeType)
 	if len(nodes) != 1 {
 		t.Fail()
 	}
@@ -60,27 +69,27 @@ func TestFindJustification(t *testing.T) {
 	}
 	{
 		code := "// JUSTIFY(test): text\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// JUSTIFY(test): text\n\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// JUSTIFY(test): text\n// This is the main function\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// This is the main function\n// JUSTIFY(test): text\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{1, 3, 1, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{1, 3, 1, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// JUSTIFY(foo): hello\n// JUSTIFY(test): text\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{1, 3, 1, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{1, 3, 1, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// JUSTIFY(test): text\n// JUSTIFY(foo): hello\nfunc main() {}"
-		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text"})
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
 	}
 	{
 		code := "// JUSTIFY(test): text\n\nconst foo = 42\n\nfunc main() {}"
@@ -88,6 +97,75 @@ func TestFindJustification(t *testing.T) {
 	}
 }
 
+func TestFindJustificationLanguages(t *testing.T) {
+	checkForJustificationsInLanguage(t, "# JUSTIFY(test): text\ndef main():\n    pass\n", "py", "function_definition", "test", &Justification{0, 2, 0, 21, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "// JUSTIFY(test): text\nfunction main() {}\n", "js", "function_declaration", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "// JUSTIFY(test): text\nfunction main(): void {}\n", "ts", "function_declaration", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "// JUSTIFY(test): text\nfn main() {}\n", "rs", "function_item", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "/* JUSTIFY(test): text */\nfn main() {}\n", "rs", "function_item", "test", &Justification{0, 3, 0, 25, "test", "text */", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "// JUSTIFY(test): text\nclass Main {}\n", "java", "class_declaration", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "// JUSTIFY(test): text\nint main() {}\n", "c", "function_definition", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeNode})
+}
+
+func TestFindTrailingJustification(t *testing.T) {
+	checkForJustificationsInLanguage(t, "package main\n\nimport \"io/ioutil\" // JUSTIFY(test): text\n", "go", "import_spec", "test", &Justification{2, 22, 2, 41, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "package main\n\nimport (\n\t\"io/ioutil\" // JUSTIFY(test): text\n)\n", "go", "import_spec", "test", &Justification{3, 16, 3, 35, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "package main\n\nfunc main() {\n\tpanic(1) /* JUSTIFY(test): text */\n}\n", "go", "call_expression", "test", &Justification{3, 13, 3, 35, "test", "text */", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "#include <stdio.h> // JUSTIFY(test): text\n", "c", "preproc_include", "test", &Justification{0, 22, 0, 41, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "#include <cstdio> // JUSTIFY(test): text\n", "cpp", "preproc_include", "test", &Justification{0, 21, 0, 40, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "import os  # JUSTIFY(test): text\n", "py", "import_statement", "test", &Justification{0, 13, 0, 32, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "import fs from \"fs\"; // JUSTIFY(test): text\n", "js", "import_statement", "test", &Justification{0, 24, 0, 43, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "import fs from \"fs\"; // JUSTIFY(test): text\n", "ts", "import_statement", "test", &Justification{0, 24, 0, 43, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "import fs from \"fs\"; // JUSTIFY(test): text\n", "tsx", "import_statement", "test", &Justification{0, 24, 0, 43, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "use std::fs; // JUSTIFY(test): text\n", "rs", "use_declaration", "test", &Justification{0, 16, 0, 35, "test", "text", "", time.Time{}, ScopeNode})
+	checkForJustificationsInLanguage(t, "import java.io.File; // JUSTIFY(test): text\n", "java", "import_declaration", "test", &Justification{0, 24, 0, 43, "test", "text", "", time.Time{}, ScopeNode})
+
+	// a trailing comment belongs to the line it's on, not to the code below
+	checkForJustificationsInLanguage(t, "package main\n\nvar a = 1 // JUSTIFY(test): text\nfunc main() {}\n", "go", "function_declaration", "test", nil)
+	checkForJustificationsInLanguage(t, "a = 1  # JUSTIFY(test): text\ndef main():\n    pass\n", "py", "function_definition", "test", nil)
+	// only comments after the node count
+	checkForJustificationsInLanguage(t, "package main\n\nvar a = f(/* JUSTIFY(test): text */ 1)\n", "go", "call_expression", "test", nil)
+}
+
+func TestFindJustificationScopes(t *testing.T) {
+	{
+		code := "// JUSTIFY(test): text\nfunc main() {\n\tif true {\n\t\tpanic(1)\n\t}\n}"
+		checkForJustificationsInLanguage(t, code, "go", "call_expression", "test", &Justification{0, 3, 0, 22, "test", "text", "", time.Time{}, ScopeEnclosing})
+	}
+	{
+		code := "func main() {\n\t// JUSTIFY(test): text\n\tif true {\n\t\tpanic(1)\n\t}\n}"
+		checkForJustificationsInLanguage(t, code, "go", "call_expression", "test", &Justification{1, 4, 1, 23, "test", "text", "", time.Time{}, ScopeEnclosing})
+	}
+	{
+		code := "// JUSTIFY-FILE(test): text\npackage main\n\nfunc main() {}"
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 27, "test", "text", "", time.Time{}, ScopeFile})
+	}
+	{
+		code := "package main\n\n// JUSTIFY-FILE(test): text\nfunc main() {}"
+		checkForJustifications(t, code, "test", &Justification{2, 3, 2, 27, "test", "text", "", time.Time{}, ScopeFile})
+	}
+	{
+		code := "// JUSTIFY-FILE(test): file\npackage main\n\n// JUSTIFY-BEGIN(test): region\nfunc main() {}\n// JUSTIFY-END\n"
+		checkForJustifications(t, code, "test", &Justification{3, 3, 3, 30, "test", "region", "", time.Time{}, ScopeRegion})
+	}
+	{
+		code := "// JUSTIFY-BEGIN(test): outer\n// JUSTIFY-BEGIN(other): inner\n// JUSTIFY-END\nfunc main() {}\n// JUSTIFY-END\n"
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 29, "test", "outer", "", time.Time{}, ScopeRegion})
+	}
+	{
+		code := "// JUSTIFY-BEGIN(test): text\n// JUSTIFY-END\nfunc main() {}\n"
+		checkForJustifications(t, code, "test", nil)
+	}
+	{
+		code := "// JUSTIFY-BEGIN(test): text\n// JUSTIFY(test): node\nfunc main() {}\n// JUSTIFY-END\n"
+		checkForJustifications(t, code, "test", &Justification{1, 3, 1, 22, "test", "node", "", time.Time{}, ScopeNode})
+	}
+	{
+		code := "// JUSTIFY-BEGIN(test)\nfunc main() {}\n// JUSTIFY-END\n"
+		checkForJustifications(t, code, "test", &Justification{0, 3, 0, 22, "test", "", "", time.Time{}, ScopeRegion})
+	}
+}
+
 func TestExtractJustification(t *testing.T) {
 	{
 		j := ExtractJustifications("", 0, 0)
@@ -124,50 +202,192 @@ func TestExtractJustification(t *testing.T) {
 		if len(j) != 1 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 0, 0, 20, "foo", "message"}, j[0])
+		assertJustification(t, Justification{0, 0, 0, 20, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
 	}
 	{
 		j := ExtractJustifications("JUSTIFY(foo): message", 0, 0)
 		if len(j) != 1 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 0, 0, 21, "foo", "message"}, j[0])
+		assertJustification(t, Justification{0, 0, 0, 21, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
 	}
 	{
 		j := ExtractJustifications("// JUSTIFY(foo): message", 0, 0)
 		if len(j) != 1 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 3, 0, 24, "foo", "message"}, j[0])
+		assertJustification(t, Justification{0, 3, 0, 24, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
 	}
 	{
 		j := ExtractJustifications("/* JUSTIFY(foo): message */", 0, 0)
 		if len(j) != 1 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 3, 0, 27, "foo", "message */"}, j[0])
+		assertJustification(t, Justification{0, 3, 0, 27, "foo", "message */", "", time.Time{}, ScopeNode}, j[0])
 	}
 	{
 		j := ExtractJustifications("/*\n * This is my function that does things\n *\n * JUSTIFY(foo): message\n */", 0, 0)
 		if len(j) != 1 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{3, 3, 3, 24, "foo", "message"}, j[0])
+		assertJustification(t, Justification{3, 3, 3, 24, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
 	}
 	{
 		j := ExtractJustifications("JUSTIFY(foo,bar): message", 0, 0)
 		if len(j) != 2 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 0, 0, 25, "foo", "message"}, j[0])
-		assertJustification(t, Justification{0, 0, 0, 25, "bar", "message"}, j[1])
+		assertJustification(t, Justification{0, 0, 0, 25, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
+		assertJustification(t, Justification{0, 0, 0, 25, "bar", "message", "", time.Time{}, ScopeNode}, j[1])
 	}
 	{
 		j := ExtractJustifications("JUSTIFY(foo, bar): message", 0, 0)
 		if len(j) != 2 {
 			t.Fail()
 		}
-		assertJustification(t, Justification{0, 0, 0, 26, "foo", "message"}, j[0])
-		assertJustification(t, Justification{0, 0, 0, 26, "bar", "message"}, j[1])
+		assertJustification(t, Justification{0, 0, 0, 26, "foo", "message", "", time.Time{}, ScopeNode}, j[0])
+		assertJustification(t, Justification{0, 0, 0, 26, "bar", "message", "", time.Time{}, ScopeNode}, j[1])
+	}
+}
+
+func TestJustificationFields(t *testing.T) {
+	j := parseJustifications("// JUSTIFY(foo, bar; ticket=ABC-123; until=2026-12-31): message", 0, 0)
+	if len(j) != 2 || j[0].problem != "" {
+		fmt.Printf("j: %v\n", j)
+		t.FailNow()
+	}
+	until := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	for i, tag := range []string{"foo", "bar"} {
+		assertJustification(t, Justification{0, 3, 0, 63, tag, "message", "", time.Time{}, ScopeNode}, j[i].Justification)
+		if j[i].Ticket != "ABC-123" || !j[i].Until.Equal(until) {
+			fmt.Printf("j[%d]: %v\n", i, j[i])
+			t.Fail()
+		}
+	}
+	if j[0].details() != "ticket ABC-123, until 2026-12-31" {
+		fmt.Printf("details: %v\n", j[0].details())
+		t.Fail()
+	}
+
+	if j[0].Expired(time.Date(2026, 12, 31, 23, 59, 0, 0, time.Local)) {
+		t.Fail()
+	}
+	if !j[0].Expired(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fail()
+	}
+	if (Justification{}).Expired(time.Now()) {
+		t.Fail()
+	}
+
+	problems := map[string]string{
+		"JUSTIFY(foo; until=31.12.2026): message": "until has to be a date like 2006-01-02: 31.12.2026",
+		"JUSTIFY(foo; owner=me): message":         "unknown field owner",
+		"JUSTIFY(foo; ticket): message":           "field ticket has no value",
+		"JUSTIFY(foo;): message":                  "",
+	}
+	for text, exp := range problems {
+		j := parseJustifications(text, 0, 0)
+		if len(j) != 1 || j[0].Tag != "foo" || j[0].problem != exp {
+			fmt.Printf("text: %v\n", text)
+			fmt.Printf("j: %v\n", j)
+			t.Fail()
+		}
+	}
+}
+
+func TestCheckJustifications(t *testing.T) {
+	content := []byte("package foo\n\n// JUSTIFY(rename): used\nfunc old() {}\n\n// JUSTIFY(rename): unused\n// JUSTIFY(renmae): typo\nfunc other() {}\n")
+	plugin := &Plugin{
+		Name:       "rename",
+		Extensions: []string{"go"},
+		ErrorCodes: []string{"R001"},
+		Run: func(a *Analysis) error {
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				if n.ChildByFieldName("name").Content(a.Content) == "old" {
+					a.Report(n, "old is deprecated")
+				}
+			}
+			return nil
+		},
+	}
+	violations, err := RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	tags := []string{}
+	for _, v := range violations {
+		tags = append(tags, fmt.Sprintf("%s:%d:%d", v.tag(), v.StartLine, v.StartColumn))
+	}
+	if fmt.Sprint(tags) != "[rename:3:0 justification/unused:5:3 justification/unknown-plugin:6:3]" {
+		fmt.Printf("tags: %v\n", tags)
+		t.Fail()
+	}
+
+	content = []byte("package foo\n\n// JUSTIFY(rename/R002): wrong code\nfunc other() {}\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	if err != nil || len(violations) != 1 || violations[0].ErrorCode != "unknown-code" {
+		fmt.Printf("violations: %v\n", violations)
+		t.Fail()
+	}
+
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{Config: &Config{Disable: []string{"justification"}}})
+	if err != nil || len(violations) != 0 {
+		fmt.Printf("violations: %v\n", violations)
+		t.Fail()
+	}
+
+	content = []byte("package foo\n\n// JUSTIFY-FILE(rename): late\n// JUSTIFY-BEGIN(rename): open\nfunc old() {}\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	messages := []string{}
+	for _, v := range violations {
+		messages = append(messages, v.Message)
+	}
+	exp := "[old is deprecated invalid justification for rename: JUSTIFY-FILE has to be placed before any code invalid justification for rename: JUSTIFY-BEGIN without JUSTIFY-END]"
+	if err != nil || fmt.Sprint(messages) != exp {
+		fmt.Printf("messages: %v\n", messages)
+		t.Fail()
+	}
+
+	content = []byte("package foo\n\n// JUSTIFY-BEGIN(rename)\nfunc old() {}\n// JUSTIFY-END\n// JUSTIFY-END\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	messages = []string{}
+	for _, v := range violations {
+		justified := v.Justification != nil
+		messages = append(messages, fmt.Sprintf("%s:%d:%d %v %s", v.tag(), v.StartLine, v.StartColumn, justified, v.Message))
+	}
+	exp = "[rename:3:0 true old is deprecated justification/invalid:5:3 false invalid justification: JUSTIFY-END without JUSTIFY-BEGIN]"
+	if err != nil || fmt.Sprint(messages) != exp {
+		fmt.Printf("messages: %v\n", messages)
+		t.Fail()
+	}
+
+	content = []byte("// JUSTIFY-FILE(rename): generated\npackage foo\n\nfunc old() {}\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	if err != nil || len(violations) != 1 || !strings.HasSuffix(violations[0].String(), "= justification (file, line 1): generated\n") {
+		fmt.Printf("violations: %v\n", violations)
+		t.Fail()
+	}
+
+	_, err = RunChecksForContent([]*Plugin{{Name: "justification"}}, "a.go", content, Options{})
+	if err == nil {
+		t.Fail()
+	}
+
+	now = func() time.Time { return time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	content = []byte("package foo\n\n// JUSTIFY(rename; until=2026-12-31; ticket=ABC-123): expired\nfunc old() {}\n\n// JUSTIFY(rename; until=tomorrow): invalid\nfunc old2() {}\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{})
+	if err != nil || len(violations) != 2 {
+		fmt.Printf("violations: %v\n", violations)
+		t.FailNow()
+	}
+	if violations[0].Justification != nil || violations[0].Message != "old is deprecated (justification expired on 2026-12-31, ticket ABC-123)" {
+		fmt.Printf("violations[0]: %v\n", violations[0])
+		t.Fail()
+	}
+	if violations[1].tag() != "justification/invalid" || violations[1].Message != "invalid justification for rename: until has to be a date like 2006-01-02: tomorrow" {
+		fmt.Printf("violations[1]: %v\n", violations[1])
+		t.Fail()
 	}
 }
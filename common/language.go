@@ -0,0 +1,118 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/c"
+	"github.com/smacker/go-tree-sitter/cpp"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/java"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+type Language struct {
+	Name       string
+	Extensions []string
+	Grammar    *sitter.Language
+}
+
+type registeredLanguage struct {
+	name    string
+	grammar *sitter.Language
+}
+
+var languagesMutex sync.RWMutex
+
+// maps file extensions (without the leading dot) to their grammar
+var languages = map[string]registeredLanguage{}
+
+func init() {
+	RegisterLanguage("c", c.GetLanguage(), "c", "h")
+	RegisterLanguage("cpp", cpp.GetLanguage(), "cpp", "hpp")
+	RegisterLanguage("go", golang.GetLanguage(), "go")
+	RegisterLanguage("java", java.GetLanguage(), "java")
+	RegisterLanguage("javascript", javascript.GetLanguage(), "js", "jsx", "mjs")
+	RegisterLanguage("python", python.GetLanguage(), "py")
+	RegisterLanguage("rust", rust.GetLanguage(), "rs")
+	RegisterLanguage("tsx", tsx.GetLanguage(), "tsx")
+	RegisterLanguage("typescript", typescript.GetLanguage(), "ts")
+}
+
+// RegisterLanguage makes a grammar available for the given file extensions.
+// Previous registrations of these extensions are replaced, this includes the default languages.
+func RegisterLanguage(name string, grammar *sitter.Language, extensions ...string) {
+	languagesMutex.Lock()
+	defer languagesMutex.Unlock()
+	for _, ext := range extensions {
+		languages[ext] = registeredLanguage{name: name, grammar: grammar}
+	}
+}
+
+// SetLanguage registers a grammar for a single file extension, using the extension as the name
+func SetLanguage(ext string, lang *sitter.Language) {
+	RegisterLanguage(ext, lang, ext)
+}
+
+// Languages lists all registered languages sorted by name
+func Languages() []Language {
+	languagesMutex.RLock()
+	defer languagesMutex.RUnlock()
+
+	byName := map[string]*Language{}
+	for ext, rl := range languages {
+		lang, found := byName[rl.name]
+		if !found {
+			lang = &Language{Name: rl.name, Grammar: rl.grammar}
+			byName[rl.name] = lang
+		}
+		lang.Extensions = append(lang.Extensions, ext)
+	}
+
+	result := []Language{}
+	for _, lang := range byName {
+		sort.Strings(lang.Extensions)
+		result = append(result, *lang)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func getLanguage(ext string) *sitter.Language {
+	languagesMutex.RLock()
+	defer languagesMutex.RUnlock()
+	rl, found := languages[ext]
+	if !found {
+		return nil
+	}
+	return rl.grammar
+}
+
+func validatePlugins(plugins []*Plugin) error {
+	names := map[string]bool{}
+	for _, plugin := range plugins {
+		for _, builtin := range builtinPlugins {
+			if plugin.Name == builtin.Name {
+				return fmt.Errorf("unable to use plugin %s: name is reserved for a built-in checker", plugin.Name)
+			}
+		}
+		if names[plugin.Name] {
+			return fmt.Errorf("unable to use plugin %s: name is used by multiple plugins", plugin.Name)
+		}
+		names[plugin.Name] = true
+		for _, ext := range plugin.Extensions {
+			if getLanguage(ext) == nil {
+				return fmt.Errorf("unable to use plugin %s: unknown extension %s", plugin.Name, ext)
+			}
+		}
+	}
+	return nil
+}
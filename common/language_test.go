@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/smacker/go-tree-sitter/golang"
+)
+
+func TestLanguages(t *testing.T) {
+	found := map[string]string{}
+	for _, lang := range Languages() {
+		found[lang.Name] = strings.Join(lang.Extensions, ",")
+	}
+	exp := map[string]string{
+		"c":          "c,h",
+		"cpp":        "cpp,hpp",
+		"go":         "go",
+		"java":       "java",
+		"javascript": "js,jsx,mjs",
+		"python":     "py",
+		"rust":       "rs",
+		"tsx":        "tsx",
+		"typescript": "ts",
+	}
+	for name, extensions := range exp {
+		if found[name] != extensions {
+			fmt.Printf("%s: exp %#v, act %#v\n", name, extensions, found[name])
+			t.Fail()
+		}
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	if getLanguage("gotmpl") != nil {
+		t.FailNow()
+	}
+	err := validatePlugins([]*Plugin{{Name: "foo", Extensions: []string{"gotmpl"}}})
+	if err == nil {
+		t.FailNow()
+	}
+
+	SetLanguage("gotmpl", golang.GetLanguage())
+	defer func() {
+		languagesMutex.Lock()
+		delete(languages, "gotmpl")
+		languagesMutex.Unlock()
+	}()
+
+	err = validatePlugins([]*Plugin{{Name: "foo", Extensions: []string{"gotmpl"}}})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	root, err := parseFileContent([]byte("package foo\nfunc main() {}\n"), "gotmpl")
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(FindNamedNodes(root, "function_declaration")) != 1 {
+		t.Fail()
+	}
+
+	found := false
+	for _, lang := range Languages() {
+		if lang.Name == "gotmpl" && len(lang.Extensions) == 1 && lang.Extensions[0] == "gotmpl" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fail()
+	}
+}
@@ -0,0 +1,313 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// NOTE: this follows https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/
+// Only full document synchronization is supported, the plugins need the whole file anyway.
+
+// error codes defined by JSON-RPC
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+)
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspTextDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+type lspPosition struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDocument struct {
+	path       string
+	content    []byte
+	violations []Violation
+}
+
+type lspServer struct {
+	plugins []*Plugin
+	opts    Options
+	out     io.Writer
+
+	// columns are counted in UTF-16 code units unless the client supports UTF-8, which are the bytes the violations use
+	utf8 bool
+
+	documents map[string]*lspDocument
+	shutdown  bool
+}
+
+// ServeLSP runs a language server reading JSON-RPC messages from in and writing to out until the client sends exit.
+// Open documents are analyzed whenever they change and the violations are published as diagnostics, fixes are offered as code actions.
+// An error is returned if in is closed or the client exits without shutting the server down first.
+func ServeLSP(plugins []*Plugin, opts Options, in io.Reader, out io.Writer) error {
+	err := validatePlugins(plugins)
+	if err != nil {
+		return err
+	}
+	s := &lspServer{plugins: plugins, opts: opts, out: out, documents: map[string]*lspDocument{}}
+	reader := bufio.NewReader(in)
+	for {
+		content, err := readLSPMessage(reader)
+		if err != nil {
+			return err
+		}
+		msg := lspMessage{}
+		err = json.Unmarshal(content, &msg)
+		if err != nil {
+			err = s.respondError(nil, lspParseError, err.Error())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("language client exited without shutdown")
+			}
+			return nil
+		}
+		err = s.handle(msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read message header: %s", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message header: %s", err)
+	}
+	content := make([]byte, length)
+	_, err = io.ReadFull(r, content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read message: %s", err)
+	}
+	return content, nil
+}
+
+func (s *lspServer) write(msg map[string]any) error {
+	msg["jsonrpc"] = "2.0"
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	return err
+}
+
+func (s *lspServer) respond(id *json.RawMessage, result any) error {
+	return s.write(map[string]any{"id": id, "result": result})
+}
+
+func (s *lspServer) respondError(id *json.RawMessage, code int, message string) error {
+	return s.write(map[string]any{"id": id, "error": map[string]any{"code": code, "message": message}})
+}
+
+func (s *lspServer) notify(method string, params any) error {
+	return s.write(map[string]any{"method": method, "params": params})
+}
+
+func (s *lspServer) handle(msg lspMessage) error {
+	var params struct {
+		Capabilities struct {
+			General struct {
+				PositionEncodings []string `json:"positionEncodings"`
+			} `json:"general"`
+		} `json:"capabilities"`
+		TextDocument   lspTextDocument `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Text  *string  `json:"text"`
+		Range lspRange `json:"range"`
+	}
+	if len(msg.Params) > 0 {
+		err := json.Unmarshal(msg.Params, &params)
+		if err != nil {
+			if msg.ID == nil {
+				return nil
+			}
+			return s.respondError(msg.ID, lspInvalidParams, err.Error())
+		}
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		encoding := "utf-16"
+		for _, e := range params.Capabilities.General.PositionEncodings {
+			if e == "utf-8" {
+				encoding = e
+				s.utf8 = true
+			}
+		}
+		return s.respond(msg.ID, map[string]any{
+			"capabilities": map[string]any{
+				"positionEncoding": encoding,
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    1, // full
+					"save":      map[string]any{"includeText": true},
+				},
+				"codeActionProvider": map[string]any{"codeActionKinds": []string{"quickfix"}},
+			},
+			"serverInfo": map[string]any{"name": "check"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return s.respond(msg.ID, nil)
+	case "textDocument/didOpen":
+		s.documents[uri] = &lspDocument{path: lspURIToPath(uri), content: []byte(params.TextDocument.Text)}
+		return s.analyze(uri)
+	case "textDocument/didChange":
+		doc, found := s.documents[uri]
+		if !found || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		doc.content = []byte(params.ContentChanges[len(params.ContentChanges)-1].Text)
+		return s.analyze(uri)
+	case "textDocument/didSave":
+		doc, found := s.documents[uri]
+		if !found {
+			return nil
+		}
+		if params.Text != nil {
+			doc.content = []byte(*params.Text)
+		}
+		return s.analyze(uri)
+	case "textDocument/didClose":
+		delete(s.documents, uri)
+		return s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []Violation{}})
+	case "textDocument/codeAction":
+		return s.respond(msg.ID, s.codeActions(uri, params.Range))
+	}
+
+	if msg.ID != nil {
+		return s.respondError(msg.ID, lspMethodNotFound, "method not found: "+msg.Method)
+	}
+	// other notifications like initialized or $/cancelRequest can be ignored
+	return nil
+}
+
+func (s *lspServer) analyze(uri string) error {
+	doc := s.documents[uri]
+	violations, err := RunChecksForContent(s.plugins, doc.path, doc.content, s.opts)
+	if err != nil {
+		return s.notify("window/logMessage", map[string]any{"type": 1, "message": err.Error()})
+	}
+
+	doc.violations = []Violation{}
+	for _, vio := range violations {
+		// violations reported for other files by the plugins aren't part of this document
+		if vio.FilePath != doc.path {
+			continue
+		}
+		vio.StartColumn = s.column(doc.content, vio.StartLine, vio.StartColumn)
+		vio.EndColumn = s.column(doc.content, vio.EndLine, vio.EndColumn)
+		edits := []Edit{}
+		for _, edit := range vio.Edits {
+			edit.StartColumn = s.column(doc.content, edit.StartLine, edit.StartColumn)
+			edit.EndColumn = s.column(doc.content, edit.EndLine, edit.EndColumn)
+			edits = append(edits, edit)
+		}
+		vio.Edits = edits
+		doc.violations = append(doc.violations, vio)
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": doc.violations})
+}
+
+// column converts a column in bytes into the position encoding negotiated with the client
+func (s *lspServer) column(content []byte, line uint32, column uint32) uint32 {
+	if s.utf8 {
+		return column
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if int(line) >= len(lines) || int(column) > len(lines[line]) {
+		return column
+	}
+	return uint32(len(utf16.Encode([]rune(lines[line][:column]))))
+}
+
+func (s *lspServer) codeActions(uri string, r lspRange) []map[string]any {
+	actions := []map[string]any{}
+	doc, found := s.documents[uri]
+	if !found {
+		return actions
+	}
+	for _, vio := range doc.violations {
+		if len(vio.Edits) == 0 || vio.Justification != nil {
+			continue
+		}
+		start := lspPosition{vio.StartLine, vio.StartColumn}
+		end := lspPosition{vio.EndLine, vio.EndColumn}
+		if lspBefore(end, r.Start) || lspBefore(r.End, start) {
+			continue
+		}
+		edits := []map[string]any{}
+		for _, edit := range vio.Edits {
+			edits = append(edits, map[string]any{
+				"range":   lspRange{lspPosition{edit.StartLine, edit.StartColumn}, lspPosition{edit.EndLine, edit.EndColumn}},
+				"newText": edit.NewText,
+			})
+		}
+		actions = append(actions, map[string]any{
+			"title":       fmt.Sprintf("Fix %s: %s", vio.tag(), vio.Message),
+			"kind":        "quickfix",
+			"diagnostics": []Violation{vio},
+			"edit":        map[string]any{"changes": map[string]any{uri: edits}},
+		})
+	}
+	return actions
+}
+
+func lspBefore(a lspPosition, b lspPosition) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+// lspURIToPath turns file URIs into paths, other URIs like untitled: are kept as they are
+func lspURIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// file:///C:/foo has the path /C:/foo
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+type lspTestClient struct {
+	t      *testing.T
+	in     io.Writer
+	reader *bufio.Reader
+}
+
+func (c *lspTestClient) send(id int, method string, params any) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != 0 {
+		msg["id"] = id
+	}
+	content, _ := json.Marshal(msg)
+	_, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	if err != nil {
+		fmt.Println(err)
+		c.t.FailNow()
+	}
+}
+
+func (c *lspTestClient) receive() map[string]any {
+	content, err := readLSPMessage(c.reader)
+	if err != nil {
+		fmt.Println(err)
+		c.t.FailNow()
+	}
+	msg := map[string]any{}
+	err = json.Unmarshal(content, &msg)
+	if err != nil {
+		fmt.Println(err)
+		c.t.FailNow()
+	}
+	return msg
+}
+
+func checkLSPMessage(t *testing.T, msg map[string]any, key string, exp string) {
+	data, _ := json.Marshal(msg[key])
+	if string(data) != exp {
+		fmt.Printf("exp: %v\n", exp)
+		fmt.Printf("data: %v\n", string(data))
+		t.Fail()
+	}
+}
+
+func TestServeLSP(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- ServeLSP([]*Plugin{renamePlugin}, Options{}, serverIn, serverOut)
+		serverOut.Close()
+	}()
+	c := &lspTestClient{t: t, in: clientOut, reader: bufio.NewReader(clientIn)}
+
+	c.send(1, "initialize", map[string]any{"capabilities": map[string]any{}})
+	msg := c.receive()
+	checkLSPMessage(t, msg, "id", "1")
+	capabilities := msg["result"].(map[string]any)["capabilities"].(map[string]any)
+	if capabilities["positionEncoding"] != "utf-16" {
+		fmt.Printf("capabilities: %v\n", capabilities)
+		t.Fail()
+	}
+	c.send(0, "initialized", map[string]any{})
+
+	// the position of old is counted in UTF-16 code units, ü is two bytes but one code unit
+	uri := "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "a.go"))
+	text := "package foo\n\n// JUSTIFY(rename): still used\nfunc justified() {}\n\nvar s = \"ü\"; func old() {}\n"
+	c.send(0, "textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "go", "version": 1, "text": text}})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "method", `"textDocument/publishDiagnostics"`)
+	checkLSPMessage(t, msg, "params", `{"diagnostics":[`+
+		`{"data":{"edits":[{"newText":"unjustified","range":{"end":{"character":14,"line":3},"start":{"character":5,"line":3}}}]},"message":"justified is deprecated","range":{"end":{"character":19,"line":3},"start":{"character":0,"line":3}},"severity":3,"source":"rename"},`+
+		`{"code":"R001","data":{"edits":[{"newText":"renamed","range":{"end":{"character":21,"line":5},"start":{"character":18,"line":5}}}]},"message":"old is deprecated","range":{"end":{"character":21,"line":5},"start":{"character":18,"line":5}},"severity":1,"source":"rename"}`+
+		`],"uri":"`+uri+`"}`)
+
+	c.send(2, "textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        map[string]any{"start": map[string]any{"line": 5, "character": 19}, "end": map[string]any{"line": 5, "character": 19}},
+		"context":      map[string]any{"diagnostics": []any{}},
+	})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "id", "2")
+	actions := msg["result"].([]any)
+	if len(actions) != 1 {
+		fmt.Printf("actions: %v\n", actions)
+		t.FailNow()
+	}
+	checkLSPMessage(t, actions[0].(map[string]any), "title", `"Fix rename/R001: old is deprecated"`)
+	checkLSPMessage(t, actions[0].(map[string]any), "edit", `{"changes":{"`+uri+`":[{"newText":"renamed","range":{"end":{"character":21,"line":5},"start":{"character":18,"line":5}}}]}}`)
+
+	c.send(3, "textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        map[string]any{"start": map[string]any{"line": 0, "character": 0}, "end": map[string]any{"line": 1, "character": 0}},
+	})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "result", `[]`)
+
+	c.send(0, "textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": "package foo\n\nfunc renamed() {}\n"}},
+	})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "params", `{"diagnostics":[],"uri":"`+uri+`"}`)
+
+	c.send(4, "textDocument/hover", map[string]any{})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "error", `{"code":-32601,"message":"method not found: textDocument/hover"}`)
+
+	c.send(5, "shutdown", nil)
+	msg = c.receive()
+	checkLSPMessage(t, msg, "id", "5")
+	checkLSPMessage(t, msg, "result", "null")
+	c.send(0, "exit", nil)
+	err := <-done
+	if err != nil {
+		fmt.Println(err)
+		t.Fail()
+	}
+}
+
+func TestServeLSPUTF8(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- ServeLSP([]*Plugin{renamePlugin}, Options{}, serverIn, serverOut)
+		serverOut.Close()
+	}()
+	c := &lspTestClient{t: t, in: clientOut, reader: bufio.NewReader(clientIn)}
+
+	c.send(1, "initialize", map[string]any{"capabilities": map[string]any{"general": map[string]any{"positionEncodings": []string{"utf-8", "utf-16"}}}})
+	msg := c.receive()
+	capabilities := msg["result"].(map[string]any)["capabilities"].(map[string]any)
+	if capabilities["positionEncoding"] != "utf-8" {
+		fmt.Printf("capabilities: %v\n", capabilities)
+		t.Fail()
+	}
+
+	c.send(0, "textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": "untitled:a.go", "text": "var s = \"ü\"; func old() {}\n"}})
+	msg = c.receive()
+	checkLSPMessage(t, msg, "params", `{"diagnostics":[{"code":"R001","data":{"edits":[{"newText":"renamed","range":{"end":{"character":22,"line":0},"start":{"character":19,"line":0}}}]},"message":"old is deprecated","range":{"end":{"character":22,"line":0},"start":{"character":19,"line":0}},"severity":1,"source":"rename"}],"uri":"untitled:a.go"}`)
+
+	// exiting without shutdown is an error
+	c.send(0, "exit", nil)
+	err := <-done
+	if err == nil {
+		t.Fail()
+	}
+}
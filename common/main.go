@@ -7,27 +7,51 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
-	"github.com/smacker/go-tree-sitter/c"
-	"github.com/smacker/go-tree-sitter/cpp"
-	"github.com/smacker/go-tree-sitter/golang"
 )
 
-var languages map[string]*sitter.Language
-
 func Main(plugins ...*Plugin) {
 	// handling command line flags and parameters
-	output := flag.String("o", "terminal", "output format [terminal, csv, json]")
+	output := flag.String("o", "terminal", "output format [terminal, csv, json, sarif]")
 	version := flag.Bool("V", false, "print version and exit")
+	listLanguages := flag.Bool("languages", false, "print registered languages and exit")
+	lsp := flag.Bool("lsp", false, "run a language server on stdin and stdout instead of analyzing paths")
+	jobs := flag.Int("j", 0, "number of files analyzed in parallel, 0 uses all available CPUs")
+	configPath := flag.String("config", "", "configuration file used instead of looking for .check.yaml")
+	includes := stringsFlag{}
+	flag.Var(&includes, "include", "only analyze files matching this glob, can be given multiple times")
+	excludes := stringsFlag{}
+	flag.Var(&excludes, "exclude", "skip files and directories matching this glob, can be given multiple times")
+	noIgnore := flag.Bool("no-ignore", false, "don't honor .gitignore and .checkignore files")
+	stdinFilename := flag.String("stdin-filename", "", "analyze content read from stdin as if it was this file")
+	includeGenerated := flag.Bool("include-generated", false, "analyze files with a \"Code generated ... DO NOT EDIT\" header")
+	ruleFiles := stringsFlag{}
+	flag.Var(&ruleFiles, "rules", "YAML or JSON file with additional rules, can be given multiple times")
+	fix := flag.Bool("fix", false, "apply automatic fixes to the files and report the remaining violations")
+	diff := flag.Bool("diff", false, "print automatic fixes as a unified diff instead of reporting violations")
+	baselinePath := flag.String("baseline", "", "file with known violations that don't fail the run")
+	writeBaseline := flag.Bool("write-baseline", false, "record all unjustified violations in the -baseline file and exit")
+	diffBase := flag.String("diff-base", "", "only report violations on lines changed since the merge base with this git revision, including uncommitted changes and untracked files")
+	diffFile := flag.String("diff-file", "", "only report violations on lines changed by this unified diff, paths are relative to the working directory")
+	timeout := flag.Duration("timeout", 0, "time limit for each plugin per file, e.g. 30s, plugins exceeding it are reported, 0 means no limit")
+	keepGoing := flag.Bool("keep-going", false, "report files and plugins that fail as violations instead of stopping the run")
+	cacheDir := flag.String("cache", "", "directory caching the results of unchanged files between runs, e.g. .check-cache")
+	syntaxErrors := flag.String("syntax-errors", SyntaxErrorsError, "handling of files with syntax errors [error, warning, fatal]")
+	failOn := flag.String("fail-on", "error", "lowest severity of unjustified violations that fails the run [error, warning, info, hint]")
 
 	flag.Parse()
-	directories := flag.Args()
+	paths := flag.Args()
 
 	if version != nil && *version {
 		info, ok := debug.ReadBuildInfo()
@@ -39,31 +63,249 @@ func Main(plugins ...*Plugin) {
 		os.Exit(0)
 	}
 
-	if output != nil && *output != "terminal" && *output != "csv" && *output != "json" {
+	if listLanguages != nil && *listLanguages {
+		for _, lang := range Languages() {
+			fmt.Printf("%s: %s\n", lang.Name, strings.Join(lang.Extensions, ", "))
+		}
+		os.Exit(0)
+	}
+
+	var config *Config
+	if configPath != nil && *configPath != "" {
+		var err error
+		config, err = LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	// the configuration of the first path can set the output format
+	outputSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "o" {
+			outputSet = true
+		}
+	})
+	if !outputSet {
+		outputConfig := config
+		outputDir := ""
+		if stdinFilename != nil && *stdinFilename != "" {
+			outputDir = filepath.Dir(*stdinFilename)
+		} else if len(paths) > 0 {
+			outputDir = paths[0]
+			info, err := os.Stat(outputDir)
+			if err == nil && !info.IsDir() {
+				outputDir = filepath.Dir(outputDir)
+			}
+		}
+		if outputConfig == nil && outputDir != "" {
+			var err error
+			outputConfig, err = FindConfig(outputDir)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+		if outputConfig != nil && outputConfig.Output != "" {
+			*output = outputConfig.Output
+		}
+	}
+
+	if output != nil && *output != "terminal" && *output != "csv" && *output != "json" && *output != "sarif" {
 		fmt.Fprintf(os.Stderr, "invalid output format\n")
 		os.Exit(2)
 	}
+	threshold, err := ParseSeverity(*failOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -fail-on: %s\n", err)
+		os.Exit(2)
+	}
+
+	// turning rule files into additional plugins
+	for _, path := range ruleFiles {
+		rules, err := LoadRules(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		plugins = append(plugins, rules...)
+	}
 
 	// checking that all plugins are usable in this tool
-	for _, plugin := range plugins {
-		for _, ext := range plugin.Extensions {
-			if ext != "go" {
-				fmt.Fprintf(os.Stderr, "unable to use plugin %s: unknown extension %s\n", plugin.Name, ext)
-				os.Exit(2)
-			}
+	err = validatePlugins(plugins)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opts := Options{
+		Jobs:             *jobs,
+		Config:           config,
+		Include:          includes,
+		Exclude:          excludes,
+		NoIgnoreFiles:    *noIgnore,
+		IncludeGenerated: *includeGenerated,
+		KeepGoing:        *keepGoing,
+		Timeout:          *timeout,
+		SyntaxErrors:     *syntaxErrors,
+		CacheDir:         *cacheDir,
+	}
+
+	// "check lsp" is a shorthand for -lsp, unless there is a file or directory named lsp to analyze
+	if !*lsp && len(paths) == 1 && paths[0] == "lsp" {
+		_, err := os.Stat(paths[0])
+		*lsp = errors.Is(err, fs.ErrNotExist)
+	}
+	if *lsp {
+		err := ServeLSP(plugins, opts, os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// reading the changes before the analysis, so errors show up early
+	var changes *ChangedLines
+	if *diffBase != "" && *diffFile != "" {
+		fmt.Fprintf(os.Stderr, "unable to use -diff-base and -diff-file at the same time\n")
+		os.Exit(2)
+	} else if *diffBase != "" {
+		changes, err = ChangedLinesSince(".", *diffBase)
+	} else if *diffFile != "" {
+		var content []byte
+		content, err = os.ReadFile(*diffFile)
+		if err == nil {
+			changes, err = ParseUnifiedDiff(content, ".")
 		}
 	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// looping over all directories and passing the files to the plugins
-	violations, err := RunChecksForDirectories(plugins, directories)
+	// looping over all paths and passing the files to the plugins
+	if *fix && *diff {
+		fmt.Fprintf(os.Stderr, "unable to fix and print a diff at the same time\n")
+		os.Exit(2)
+	}
+	runOpts := RunOptions{Options: opts, Plugins: plugins, Paths: paths}
+	var stdinContent []byte
+	if stdinFilename != nil && *stdinFilename != "" {
+		if len(paths) > 0 {
+			fmt.Fprintf(os.Stderr, "unable to analyze stdin and paths at the same time\n")
+			os.Exit(2)
+		}
+		if *fix {
+			fmt.Fprintf(os.Stderr, "unable to fix stdin, use -diff instead\n")
+			os.Exit(2)
+		}
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read stdin: %s\n", err)
+			os.Exit(2)
+		}
+		runOpts.Paths = []string{*stdinFilename}
+		runOpts.Content = map[string][]byte{*stdinFilename: content}
+		stdinContent = content
+	}
+	// interrupting stops the run before the next file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	report, err := Run(ctx, runOpts)
+	stop()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
+	for _, warning := range report.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	violations := report.violations
+
+	// writing the baseline or leaving out the violations known from it
+	if *writeBaseline {
+		if *baselinePath == "" {
+			fmt.Fprintf(os.Stderr, "unable to write baseline without -baseline FILE\n")
+			os.Exit(2)
+		}
+		baseline, err := NewBaseline(*baselinePath, violations)
+		if err == nil {
+			err = baseline.Write(*baselinePath)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "recorded %d violations in %s\n", len(baseline.Entries), *baselinePath)
+		os.Exit(0)
+	}
+	if *baselinePath != "" {
+		baseline, err := LoadBaseline(*baselinePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		var stale []BaselineEntry
+		violations, _, stale = baseline.Filter(violations)
+		analyzed := paths
+		if stdinContent != nil {
+			analyzed = []string{*stdinFilename}
+		}
+		for _, entry := range stale {
+			if !baseline.covers(entry, analyzed) {
+				continue
+			}
+			tag := entry.Plugin
+			if entry.Code != "" {
+				tag += "/" + entry.Code
+			}
+			fmt.Fprintf(os.Stderr, "stale baseline entry %s in %s: %s\n", tag, entry.File, entry.Message)
+		}
+	}
+
+	// leaving out violations on lines that didn't change
+	if changes != nil {
+		violations = changes.Filter(violations)
+	}
+
+	// applying the fixes or printing them, fixed violations aren't reported anymore
+	if *fix || *diff {
+		read := os.ReadFile
+		if stdinContent != nil {
+			read = func(path string) ([]byte, error) {
+				return stdinContent, nil
+			}
+		}
+		files, err := ComputeFixes(violations, read)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, file := range files {
+			for _, vio := range file.Conflicts {
+				fmt.Fprintf(os.Stderr, "skipped conflicting fix of %s at %s:%d:%d\n", vio.tag(), vio.FilePath, vio.StartLine+1, vio.StartColumn+1)
+			}
+			if *diff {
+				fmt.Print(file.Diff())
+				continue
+			}
+			err := file.Write()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+		if *fix {
+			violations = RemainingViolations(violations, files)
+		}
+	}
 
 	// building up the report and outputting it
-	report := Report{violations: violations}
-	if output == nil || *output == "terminal" {
+	report.violations = violations
+	if *diff {
+		// the diff has been printed instead
+	} else if output == nil || *output == "terminal" {
 		for _, vio := range report.violations {
 			fmt.Println(vio.StringPretty(true))
 		}
@@ -82,100 +324,414 @@ func Main(plugins ...*Plugin) {
 			os.Exit(2)
 		}
 		fmt.Println(string(bytes))
-	}
-
-	// exit with correct code
-	for _, vio := range report.violations {
-		if vio.Justification == nil {
-			os.Exit(1)
+	} else if *output == "sarif" {
+		var buf bytes.Buffer
+		err := report.WriteSarif(&buf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to write violations to sarif: %s", err)
+			os.Exit(2)
 		}
+		fmt.Print(buf.String())
 	}
-	os.Exit(0)
+
+	os.Exit(report.exitCode(threshold))
+}
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type Options struct {
+	// Number of files analyzed in parallel, 0 or less uses all available CPUs
+	Jobs int
+
+	// Config is used for all directories if set, otherwise each directory uses the .check.yaml found by FindConfig
+	Config *Config
+
+	// Globs relative to each directory passed in, in addition to the ones in the configuration
+	Include []string
+	Exclude []string
+
+	// By default .gitignore and .checkignore files are honored and .git directories are skipped
+	NoIgnoreFiles bool
+
+	// By default files with a "Code generated ... DO NOT EDIT" header found in directories are skipped
+	IncludeGenerated bool
+
+	// By default a file that can't be analyzed fails the run, with KeepGoing it's reported as a violation of the plugin internal
+	KeepGoing bool
+
+	// Timeout limits every call of Run per file and of Finalize, 0 means no limit.
+	// Plugins exceeding it are reported as violations of the plugin internal and left running in the background.
+	Timeout time.Duration
+
+	// SyntaxErrors is one of the SyntaxErrors constants, by default syntax errors are reported as violations of the plugin syntax.
+	// With SyntaxErrorsWarning their severity is warning regardless of the configuration, with SyntaxErrorsFatal they fail the run.
+	SyntaxErrors string
+
+	// CacheDir enables the cache in this directory, e.g. .check-cache.
+	// Plugins with a Version aren't run again for files whose content and configuration didn't change since a run using the same directory.
+	CacheDir string
+}
+
+// RunOptions describe everything analyzed by Run
+type RunOptions struct {
+	Options
+
+	Plugins []*Plugin
+
+	// Paths are the files and directories to analyze
+	Paths []string
+
+	// Content is used instead of reading the files given in Paths, e.g. for unsaved buffers of an editor.
+	// These files don't have to exist, the path is used in violations, for looking up the configuration and to determine the language.
+	Content map[string][]byte
+}
+
+type sourceFile struct {
+	path   string
+	ext    string
+	config *Config
+
+	// if set the file isn't read from disk
+	content []byte
+
+	// walked files were found in a directory passed in, only they are filtered, e.g. if they are generated
+	walked bool
 }
 
 func RunChecksForDirectories(plugins []*Plugin, directories []string) ([]Violation, error) {
-	violations := []Violation{}
-	for _, dir := range directories {
-		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+	return RunChecksForDirectoriesWithOptions(plugins, directories, Options{})
+}
+
+func RunChecksForDirectoriesWithOptions(plugins []*Plugin, directories []string, opts Options) ([]Violation, error) {
+	return RunChecksForFiles(plugins, directories, opts)
+}
+
+// RunChecksForFiles analyzes files and directories.
+// Paths passed in are always analyzed, the filters of Options and the configuration only apply to the contents of directories.
+func RunChecksForFiles(plugins []*Plugin, paths []string, opts Options) ([]Violation, error) {
+	report, err := Run(context.Background(), RunOptions{Options: opts, Plugins: plugins, Paths: paths})
+	if err != nil {
+		return nil, err
+	}
+	return report.violations, nil
+}
+
+// RunChecksForContent analyzes content that isn't read from disk, e.g. an unsaved buffer of an editor.
+// The path is used in violations, for looking up the configuration and to determine the language.
+func RunChecksForContent(plugins []*Plugin, path string, content []byte, opts Options) ([]Violation, error) {
+	if content == nil {
+		content = []byte{}
+	}
+	report, err := Run(context.Background(), RunOptions{Options: opts, Plugins: plugins, Paths: []string{path}, Content: map[string][]byte{path: content}})
+	if err != nil {
+		return nil, err
+	}
+	return report.violations, nil
+}
+
+// Run analyzes all paths and never exits the process.
+// Files that fail are collected in a *RunError, unless KeepGoing is set and they are reported as violations instead.
+// Cancelling the context stops the run before the next file and returns the context's error.
+func Run(ctx context.Context, opts RunOptions) (*Report, error) {
+	err := validatePlugins(opts.Plugins)
+	if err != nil {
+		return nil, err
+	}
+	err = validateSyntaxErrors(opts.SyntaxErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []sourceFile{}
+	configs := []*Config{}
+	warnings := []string{}
+	for _, path := range opts.Paths {
+		content, isContent := opts.Content[path]
+		isDir := false
+		if !isContent {
+			info, err := os.Stat(path)
 			if err != nil {
-				return fmt.Errorf("unable to walk directory %s: %s", path, err)
+				return nil, fmt.Errorf("unable to analyze %s: %s", path, err)
 			}
-			if d.IsDir() {
-				return nil
+			isDir = info.IsDir()
+		}
+		dir := path
+		if !isDir {
+			dir = filepath.Dir(path)
+		}
+		config, configWarnings, err := findConfigForOptions(dir, opts.Plugins, opts.Options)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+		for _, warning := range configWarnings {
+			if !containsString(warnings, warning) {
+				warnings = append(warnings, warning)
 			}
+		}
 
-			name := d.Name()
-			ext := filepath.Ext(name)
-			ext = strings.TrimPrefix(ext, ".")
-			for _, plugin := range plugins {
-				if plugin.handlesExtension(ext) && plugin.Run != nil {
-					vios, err := makePluginHandleFile(plugin, path, ext)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "%s\n", err)
-						os.Exit(2)
-					}
-					violations = append(violations, vios...)
-				}
+		if isDir {
+			dirFiles, err := collectFiles(path, config, opts.Options)
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		})
+			files = append(files, dirFiles...)
+		} else {
+			file := newSourceFile(path, config)
+			file.content = content
+			files = append(files, file)
+		}
+	}
+
+	violations, err := runChecks(ctx, opts.Plugins, files, configs, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	plugins := append(append([]*Plugin{}, opts.Plugins...), builtinPlugins...)
+	return &Report{violations: violations, plugins: plugins, warnings: warnings}, nil
+}
 
+func findConfigForOptions(dir string, plugins []*Plugin, opts Options) (*Config, []string, error) {
+	config := opts.Config
+	if config == nil {
+		var err error
+		config, err = FindConfig(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	warnings, err := config.validate(plugins)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, warnings, nil
+}
+
+func newSourceFile(path string, config *Config) sourceFile {
+	ext := filepath.Ext(path)
+	ext = strings.TrimPrefix(ext, ".")
+	return sourceFile{path: path, ext: ext, config: config}
+}
+
+func runChecks(ctx context.Context, plugins []*Plugin, files []sourceFile, configs []*Config, opts Options) ([]Violation, error) {
+	var c *cache
+	if opts.CacheDir != "" {
+		var err error
+		c, err = openCache(opts.CacheDir)
 		if err != nil {
 			return nil, err
 		}
 	}
+	// every file gets its own slot so the output order doesn't depend on scheduling
+	results := make([][]Violation, len(files))
+	facts := make([][]fileFacts, len(files))
+	errs := make([][]*FileError, len(files))
+	calls := map[*Plugin]*pluginCalls{}
+	for _, plugin := range plugins {
+		calls[plugin] = newPluginCalls(plugin)
+	}
+
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i], facts[i], errs[i] = analyzeFile(ctx, plugins, files[i], opts, calls, c)
+			}
+		}()
+	}
+feed:
+	for i := range files {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	violations := []Violation{}
+	failures := []*FileError{}
+	configByPath := map[string]*Config{}
+	sources := map[string]sourceFile{}
+	factsByPlugin := map[string][]fileFacts{}
+	for i := range files {
+		violations = append(violations, results[i]...)
+		configByPath[files[i].path] = files[i].config
+		sources[files[i].path] = files[i]
+		for _, f := range facts[i] {
+			factsByPlugin[f.plugin] = append(factsByPlugin[f.plugin], f)
+		}
+		for _, failure := range errs[i] {
+			if opts.KeepGoing || failure.isolated() {
+				violations = append(violations, failure.violation())
+			} else {
+				failures = append(failures, failure)
+			}
+		}
+	}
+	if len(failures) > 0 && !opts.KeepGoing {
+		return nil, &RunError{Errors: failures}
+	}
 
 	for _, plugin := range plugins {
-		if plugin.Finalize != nil {
+		enabled := false
+		for _, config := range configs {
+			if config.pluginEnabled(plugin.Name) {
+				enabled = true
+			}
+		}
+		if plugin.Finalize != nil && enabled {
 			a := &Analysis{
 				pluginName: plugin.Name,
+				finalize:   &finalizeData{files: factsByPlugin[plugin.Name], sources: sources, parsed: map[string]*parsedSource{}},
 			}
 
-			err := plugin.Finalize(a)
+			var err error
+			if calls[plugin].stuck() {
+				// Finalize would run alongside the call that timed out and see the state of the plugin change
+				err = fmt.Errorf("%w, an earlier call is still running", ErrTimeout)
+			} else {
+				err = callPlugin(ctx, plugin.Finalize, a, opts.Timeout, calls[plugin])
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if err != nil {
-				return nil, fmt.Errorf("[%s] unable to finalize: %s", plugin.Name, err)
+				failure := &FileError{Plugin: plugin.Name, Err: err}
+				if !opts.KeepGoing && !failure.isolated() {
+					return nil, &RunError{Errors: []*FileError{failure}}
+				}
+				violations = append(violations, failure.violation())
+				continue
+			}
+			// violations of files that weren't analyzed and of no file at all only get the plugin's severity
+			for i := range a.violations {
+				a.violations[i].Severity = severityFor(plugin, configByPath[a.violations[i].FilePath], a.violations[i].ErrorCode)
 			}
 			violations = append(violations, a.violations...)
 		}
 	}
-	return violations, nil
+	return dropUsedJustifications(violations), nil
 }
 
-func SetLanguage(ext string, lang *sitter.Language) {
-	languages[ext] = lang
+// dropUsedJustifications removes the reports of unused justifications that justify a violation reported by Finalize after all
+func dropUsedJustifications(violations []Violation) []Violation {
+	type pathKey struct {
+		path string
+		key  justificationKey
+	}
+	used := map[pathKey]bool{}
+	for _, v := range violations {
+		if v.Justification != nil {
+			used[pathKey{v.FilePath, v.Justification.key()}] = true
+		}
+		if v.expiredJustification != nil {
+			used[pathKey{v.FilePath, v.expiredJustification.key()}] = true
+		}
+	}
+	result := []Violation{}
+	for _, v := range violations {
+		if v.unusedJustification != nil && used[pathKey{v.FilePath, v.unusedJustification.key()}] {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
 }
 
-func getLanguage(ext string) *sitter.Language {
-	lang, found := languages[ext]
-	if found {
-		return lang
+func collectFiles(dir string, config *Config, opts Options) ([]sourceFile, error) {
+	var ignore *ignoreMatcher
+	if !opts.NoIgnoreFiles {
+		var err error
+		ignore, err = newIgnoreMatcher(dir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to walk directory %s: %s", dir, err)
+		}
 	}
 
-	// default languages
-	switch ext {
-	case "c":
-		return c.GetLanguage()
-	case "cpp":
-		return cpp.GetLanguage()
-	case "go":
-		return golang.GetLanguage()
-	case "h":
-		return c.GetLanguage()
-	case "hpp":
-		return cpp.GetLanguage()
+	files := []sourceFile{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("unable to walk directory %s: %s", path, err)
+		}
+		// the directories passed in are always analyzed, even if they are excluded
+		if path != dir {
+			included := config.includesPath(path, d.IsDir())
+			rel, err := filepath.Rel(dir, path)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				if matchAnyGlob(opts.Exclude, rel) {
+					included = false
+				} else if !d.IsDir() && len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel) {
+					included = false
+				}
+			}
+			if included && ignore != nil {
+				ignored, err := ignore.ignored(path, d.IsDir())
+				if err != nil {
+					return fmt.Errorf("unable to walk directory %s: %s", path, err)
+				}
+				if ignored && d.IsDir() && (includesDependencyDir(opts.Include, path) || (config != nil && includesDependencyDir(config.Include, path))) {
+					ignored = false
+				}
+				included = !ignored
+			}
+			if !included {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		file := newSourceFile(path, config)
+		file.walked = true
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return files, nil
 }
 
 func parseFileContent(content []byte, ext string) (*sitter.Node, error) {
+	return parseFileContentContext(context.Background(), content, ext)
+}
+
+func parseFileContentContext(ctx context.Context, content []byte, ext string) (*sitter.Node, error) {
 	parser := sitter.NewParser()
 
 	lang := getLanguage(ext)
 	if lang == nil {
 		return nil, errors.New("unknown extension")
 	}
+	parser.SetLanguage(lang)
 
-	tree, err := parser.ParseCtx(context.Background(), nil, content)
+	tree, err := parser.ParseCtx(ctx, nil, content)
 	if err != nil {
 		return nil, err
 	}
@@ -183,28 +739,323 @@ func parseFileContent(content []byte, ext string) (*sitter.Node, error) {
 	return root, nil
 }
 
-func makePluginHandleFile(plugin *Plugin, path string, ext string) ([]Violation, error) {
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read file %s: %s", path, err)
+// The file is read and parsed only once, all interested plugins share the same tree.
+// With a cache the file is only parsed if a plugin has no entry for it, plugins with an entry are restored instead of run.
+// Every call of a plugin is tracked in calls, Serial plugins are only run while holding it.
+// A failing plugin doesn't stop the other plugins, its justifications aren't checked though.
+func analyzeFile(ctx context.Context, plugins []*Plugin, file sourceFile, opts Options, calls map[*Plugin]*pluginCalls, c *cache) ([]Violation, []fileFacts, []*FileError) {
+	if ctx.Err() != nil {
+		return nil, nil, nil
 	}
-	root, err := parseFileContent(content, ext)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse file %s: %s", path, err)
+	interested := []*Plugin{}
+	for _, plugin := range plugins {
+		if plugin.handlesExtension(file.ext) && plugin.Run != nil && file.config.pluginEnabled(plugin.Name) {
+			interested = append(interested, plugin)
+		}
+	}
+	if len(interested) == 0 {
+		return nil, nil, nil
+	}
+
+	content := file.content
+	if content == nil {
+		var err error
+		content, err = os.ReadFile(file.path)
+		if err != nil {
+			return nil, nil, []*FileError{{Path: file.path, Err: fmt.Errorf("unable to read file %s: %s", file.path, err)}}
+		}
+	}
+	if file.walked && !opts.IncludeGenerated && isGenerated(content) {
+		return nil, nil, nil
+	}
+	fileKey := c.fileKey(file, content)
+	var root *sitter.Node
+	parse := func() *FileError {
+		if root != nil {
+			return nil
+		}
+		var err error
+		root, err = parseFileContentContext(ctx, content, file.ext)
+		if err != nil {
+			return &FileError{Path: file.path, Err: fmt.Errorf("unable to parse file %s: %s", file.path, err)}
+		}
+		return nil
 	}
 
+	syntaxKey := c.key(fileKey, syntaxPlugin.Name, "")
+	var syntaxErrors []Violation
+	entry, hit := c.get(syntaxKey)
+	if hit {
+		syntaxErrors = entry.violations()
+	} else {
+		failure := parse()
+		if failure != nil {
+			return nil, nil, []*FileError{failure}
+		}
+		syntaxErrors = checkSyntax(file.path, content, root)
+		c.put(syntaxKey, syntaxErrors, nil, nil)
+	}
+	if len(syntaxErrors) > 0 && opts.SyntaxErrors == SyntaxErrorsFatal {
+		first := syntaxErrors[0]
+		return nil, nil, []*FileError{{Path: file.path, Err: fmt.Errorf("unable to parse file %s: syntax error at %d:%d", file.path, first.StartLine+1, first.StartColumn+1)}}
+	}
+
+	violations := []Violation{}
+	if file.config.pluginEnabled(syntaxPlugin.Name) {
+		for i := range syntaxErrors {
+			syntaxErrors[i].Severity = severityFor(syntaxPlugin, file.config, syntaxErrors[i].ErrorCode)
+			if opts.SyntaxErrors == SyntaxErrorsWarning {
+				syntaxErrors[i].Severity = SeverityWarning
+			}
+		}
+		violations = append(violations, syntaxErrors...)
+	}
+
+	failures := []*FileError{}
+	facts := []fileFacts{}
+	ran := []*Plugin{}
+	for _, plugin := range interested {
+		if plugin.SkipSyntaxErrors && len(syntaxErrors) > 0 {
+			continue
+		}
+		key := ""
+		if plugin.cacheable() {
+			key = c.key(fileKey, plugin.Name, plugin.Version)
+		}
+		entry, hit := c.get(key)
+		if !hit {
+			failure := parse()
+			if failure != nil {
+				return nil, nil, []*FileError{failure}
+			}
+			a, err := makePluginHandleFile(ctx, plugin, file, content, root, opts.Timeout, calls[plugin])
+			if err != nil {
+				failures = append(failures, &FileError{Path: file.path, Plugin: plugin.Name, Err: err})
+				continue
+			}
+			c.put(key, a.violations, a.state, a.facts)
+			entry = &cacheEntry{State: a.state, Facts: a.facts}
+			violations = append(violations, a.violations...)
+		} else {
+			err := makePluginRestoreFile(ctx, plugin, file, entry, opts.Timeout, calls[plugin])
+			if err != nil {
+				failures = append(failures, &FileError{Path: file.path, Plugin: plugin.Name, Err: err})
+				continue
+			}
+			violations = append(violations, entry.violations()...)
+		}
+		ran = append(ran, plugin)
+		facts = append(facts, fileFacts{plugin: plugin.Name, path: file.path, facts: entry.Facts})
+	}
+	if file.config.pluginEnabled(justificationPlugin.Name) {
+		// the results of the plugins only stay the same if all of them came from the cache
+		key := c.key(fileKey, justificationPlugin.Name, "", justificationCacheExtra(plugins, ran)...)
+		entry, hit := c.get(key)
+		var vios []Violation
+		if root == nil && hit {
+			vios = entry.violations()
+		} else {
+			failure := parse()
+			if failure != nil {
+				return nil, nil, []*FileError{failure}
+			}
+			vios = checkJustifications(plugins, ran, file.path, content, root, violations)
+			if allCacheable(ran) {
+				c.put(key, vios, nil, nil)
+			}
+		}
+		for i := range vios {
+			vios[i].Severity = severityFor(justificationPlugin, file.config, vios[i].ErrorCode)
+		}
+		violations = append(violations, vios...)
+	}
+	return violations, facts, failures
+}
+
+func allCacheable(plugins []*Plugin) bool {
+	for _, plugin := range plugins {
+		if !plugin.cacheable() {
+			return false
+		}
+	}
+	return true
+}
+
+// makePluginHandleFile returns the analysis after Run, with the violations, state and facts of the file
+func makePluginHandleFile(ctx context.Context, plugin *Plugin, file sourceFile, content []byte, root *sitter.Node, timeout time.Duration, calls *pluginCalls) (*Analysis, error) {
 	a := &Analysis{
 		Content:   content,
 		Root:      root,
-		FilePath:  path,
-		Extension: ext,
+		FilePath:  file.path,
+		Extension: file.ext,
 
 		pluginName: plugin.Name,
+		config:     file.config,
 	}
 
-	err = plugin.Run(a)
+	err := callPlugin(ctx, plugin.Run, a, timeout, calls)
 	if err != nil {
-		return nil, fmt.Errorf("[%s] unable to check file %s: %s", plugin.Name, path, err)
+		return nil, err
+	}
+	for i := range a.violations {
+		a.violations[i].Severity = severityFor(plugin, file.config, a.violations[i].ErrorCode)
+	}
+	return a, nil
+}
+
+// makePluginRestoreFile hands the persisted state back to plugins with Restore, the violations and facts are the cached ones
+func makePluginRestoreFile(ctx context.Context, plugin *Plugin, file sourceFile, entry *cacheEntry, timeout time.Duration, calls *pluginCalls) error {
+	if plugin.Restore == nil {
+		return nil
+	}
+	a := &Analysis{
+		FilePath:  file.path,
+		Extension: file.ext,
+
+		pluginName: plugin.Name,
+		config:     file.config,
+	}
+	restore := func(a *Analysis) error {
+		return plugin.Restore(a, entry.State)
+	}
+	return callPlugin(ctx, restore, a, timeout, calls)
+}
+
+// pluginCalls tracks the calls of a plugin that haven't returned yet, for Serial plugins it's also the lock held while one runs.
+// A call exceeding the timeout is left running, later calls of a Serial plugin fail right away instead of waiting for it until it returns.
+type pluginCalls struct {
+	// held is only set for Serial plugins
+	held chan struct{}
+
+	mu sync.Mutex
+	// running maps every call that hasn't returned to whether it timed out, abandoned is closed while one of them did
+	running   map[*Analysis]bool
+	abandoned chan struct{}
+}
+
+func newPluginCalls(plugin *Plugin) *pluginCalls {
+	calls := &pluginCalls{running: map[*Analysis]bool{}, abandoned: make(chan struct{})}
+	if plugin.Serial {
+		calls.held = make(chan struct{}, 1)
+	}
+	return calls
+}
+
+func (c *pluginCalls) acquire(ctx context.Context, a *Analysis) error {
+	if c.held != nil {
+		c.mu.Lock()
+		abandoned := c.abandoned
+		c.mu.Unlock()
+		select {
+		case <-abandoned:
+			return fmt.Errorf("%w, an earlier call is still running", ErrTimeout)
+		default:
+		}
+		select {
+		case c.held <- struct{}{}:
+		case <-abandoned:
+			return fmt.Errorf("%w, an earlier call is still running", ErrTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	c.mu.Lock()
+	c.running[a] = false
+	c.mu.Unlock()
+	return nil
+}
+
+// abandon marks a call that timed out, unless it returned in the meantime
+func (c *pluginCalls) abandon(a *Analysis) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.running[a]
+	if !found {
+		return
+	}
+	c.running[a] = true
+	select {
+	case <-c.abandoned:
+	default:
+		close(c.abandoned)
+	}
+}
+
+// release re-arms abandoned once no call that timed out is running anymore, so calls of Serial plugins wait for each other again
+func (c *pluginCalls) release(a *Analysis) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.running, a)
+	if !c.stuckLocked() {
+		select {
+		case <-c.abandoned:
+			c.abandoned = make(chan struct{})
+		default:
+		}
+	}
+	if c.held != nil {
+		<-c.held
+	}
+}
+
+// stuck tells whether a call that timed out is still running
+func (c *pluginCalls) stuck() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stuckLocked()
+}
+
+func (c *pluginCalls) stuckLocked() bool {
+	for _, abandoned := range c.running {
+		if abandoned {
+			return true
+		}
+	}
+	return false
+}
+
+// callPlugin calls Run or Finalize in its own goroutine, so a panic or exceeding the timeout only fails this call.
+// A call that timed out is left running, the plugin can notice it through Analysis.Context.
+func callPlugin(ctx context.Context, fn func(*Analysis) error, a *Analysis, timeout time.Duration, calls *pluginCalls) error {
+	if calls != nil {
+		err := calls.acquire(ctx, a)
+		if err != nil {
+			return err
+		}
+	}
+
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	a.ctx = callCtx
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			r := recover()
+			if calls != nil {
+				calls.release(a)
+			}
+			if r != nil {
+				done <- &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		done <- fn(a)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if calls != nil {
+			calls.abandon(a)
+		}
+		return fmt.Errorf("%w after %s", ErrTimeout, timeout)
 	}
-	return a.violations, nil
 }
@@ -0,0 +1,476 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func writeBenchmarkFiles(b *testing.B, count int) string {
+	dir := b.TempDir()
+	var sb strings.Builder
+	sb.WriteString("package foo\n\nimport (\n\t\"fmt\"\n\t\"io/ioutil\"\n)\n\n")
+	for i := 0; i < 50; i++ {
+		sb.WriteString(fmt.Sprintf("func foo%d(a int, b string) {\n\tif a > %d {\n\t\tfmt.Println(b)\n\t}\n}\n\n", i, i))
+	}
+	for i := 0; i < count; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file_%03d.go", i))
+		err := os.WriteFile(path, []byte(sb.String()), 0o644)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func makeBenchmarkPlugins(count int) []*Plugin {
+	plugins := []*Plugin{}
+	for i := 0; i < count; i++ {
+		plugins = append(plugins, &Plugin{
+			Name:       fmt.Sprintf("bench-%d", i),
+			Extensions: []string{"go"},
+			Run: func(a *Analysis) error {
+				FindNamedNodes(a.Root, "import_spec")
+				return nil
+			},
+		})
+	}
+	return plugins
+}
+
+func TestRunChecksForDirectoriesSharesTree(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte("package foo\n"), 0o644)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	analyses := []*Analysis{}
+	run := func(a *Analysis) error {
+		analyses = append(analyses, a)
+		return nil
+	}
+	plugins := []*Plugin{
+		{Name: "a", Extensions: []string{"go"}, Run: run},
+		{Name: "b", Extensions: []string{"go"}, Run: run},
+		{Name: "c", Extensions: []string{"c"}, Run: run},
+	}
+	_, err = RunChecksForDirectories(plugins, []string{dir})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(analyses) != 2 {
+		fmt.Printf("len(analyses): %d\n", len(analyses))
+		t.FailNow()
+	}
+	if analyses[0].Root != analyses[1].Root || &analyses[0].Content[0] != &analyses[1].Content[0] {
+		t.Fail()
+	}
+}
+
+func TestRunChecksForDirectoriesParallel(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 32; i++ {
+		err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file_%02d.go", i)), []byte("package foo\n\nimport \"fmt\"\n"), 0o644)
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+	}
+
+	inFlight := int32(0)
+	overlapped := false
+	runs := 0
+	finalizedAfter := -1
+	reporter := &Plugin{
+		Name:       "reporter",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			for _, n := range FindNamedNodes(a.Root, "import_spec") {
+				a.Report(n, a.FilePath)
+			}
+			return nil
+		},
+	}
+	stateful := &Plugin{
+		Name:       "stateful",
+		Extensions: []string{"go"},
+		Serial:     true,
+		Run: func(a *Analysis) error {
+			if atomic.AddInt32(&inFlight, 1) > 1 {
+				overlapped = true
+			}
+			runs++
+			atomic.AddInt32(&inFlight, -1)
+			return nil
+		},
+		Finalize: func(a *Analysis) error {
+			finalizedAfter = runs
+			return nil
+		},
+	}
+	plugins := []*Plugin{reporter, stateful}
+
+	exp, err := RunChecksForDirectoriesWithOptions(plugins, []string{dir}, Options{Jobs: 1})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	for _, jobs := range []int{0, 2, 8} {
+		runs = 0
+		act, err := RunChecksForDirectoriesWithOptions(plugins, []string{dir}, Options{Jobs: jobs})
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+		if len(exp) != len(act) {
+			fmt.Printf("jobs=%d len(exp)=%d len(act)=%d\n", jobs, len(exp), len(act))
+			t.FailNow()
+		}
+		for i := range exp {
+			if exp[i].FilePath != act[i].FilePath || exp[i].Message != act[i].Message {
+				fmt.Printf("jobs=%d exp[%d]: %v\n", jobs, i, exp[i])
+				fmt.Printf("jobs=%d act[%d]: %v\n", jobs, i, act[i])
+				t.FailNow()
+			}
+		}
+		if overlapped || finalizedAfter != 32 {
+			fmt.Printf("jobs=%d overlapped=%v finalizedAfter=%d\n", jobs, overlapped, finalizedAfter)
+			t.FailNow()
+		}
+	}
+}
+
+func BenchmarkRunChecksForDirectories(b *testing.B) {
+	dir := writeBenchmarkFiles(b, 20)
+	for _, count := range []int{1, 4, 16} {
+		plugins := makeBenchmarkPlugins(count)
+		b.Run(fmt.Sprintf("plugins=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := RunChecksForDirectoriesWithOptions(plugins, []string{dir}, Options{Jobs: 1})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParsePerPlugin reads and parses every file once per plugin for comparison
+func BenchmarkParsePerPlugin(b *testing.B) {
+	dir := writeBenchmarkFiles(b, 20)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, count := range []int{1, 4, 16} {
+		plugins := makeBenchmarkPlugins(count)
+		b.Run(fmt.Sprintf("plugins=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, e := range entries {
+					path := filepath.Join(dir, e.Name())
+					for _, plugin := range plugins {
+						_, _, errs := analyzeFile(context.Background(), []*Plugin{plugin}, sourceFile{path: path, ext: "go"}, Options{}, nil, nil)
+						if len(errs) > 0 {
+							b.Fatal(errs[0])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunChecksForFilesAndContent(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		".check.yaml":     "exclude: [\"*_skip.go\"]\n",
+		"a.go":            "package foo\n\nfunc a() {}\n",
+		"a_skip.go":       "package foo\n\nfunc skipped() {}\n",
+		"sub/b.go":        "package foo\n\nfunc b() {}\n",
+		"sub/b_skip.go":   "package foo\n\nfunc alsoSkipped() {}\n",
+		"sub/readme.txt":  "func c() {}\n",
+		"other/c_skip.go": "package foo\n\nfunc c() {}\n",
+	})
+	plugin := &Plugin{
+		Name:       "functions",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				a.Reportf(n, "function %s", n.ChildByFieldName("name").Content(a.Content))
+			}
+			return nil
+		},
+	}
+
+	paths := []string{filepath.Join(root, "a_skip.go"), filepath.Join(root, "sub"), filepath.Join(root, "sub", "readme.txt")}
+	violations, err := RunChecksForFiles([]*Plugin{plugin}, paths, Options{Jobs: 1})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	messages := []string{}
+	for _, v := range violations {
+		messages = append(messages, v.Message)
+	}
+	if fmt.Sprint(messages) != "[function skipped function b]" {
+		fmt.Printf("messages: %#v\n", messages)
+		t.Fail()
+	}
+
+	_, err = RunChecksForFiles([]*Plugin{plugin}, []string{filepath.Join(root, "missing.go")}, Options{})
+	if err == nil {
+		t.Fail()
+	}
+
+	path := filepath.Join(root, "a.go")
+	content := []byte("package foo\n\n// JUSTIFY(functions): unsaved\nfunc unsaved() {}\n")
+	violations, err = RunChecksForContent([]*Plugin{plugin}, path, content, Options{})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(violations) != 1 || violations[0].Message != "function unsaved" || violations[0].FilePath != path || violations[0].Justification == nil {
+		fmt.Printf("violations: %v\n", violations)
+		t.Fail()
+	}
+
+	violations, err = RunChecksForContent([]*Plugin{plugin}, "buffer.txt", content, Options{})
+	if err != nil || len(violations) != 0 {
+		t.Fail()
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		"a.go": "package foo\n\n// JUSTIFY(fragile): not unused, the plugin failed\nfunc a() {}\n",
+		"b.go": "package foo\n\nfunc b() {}\n",
+		"c.go": "package foo\n\nfunc c() {}\n",
+	})
+	errFragile := errors.New("can't handle this")
+	fragile := &Plugin{
+		Name:       "fragile",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			if filepath.Base(a.FilePath) != "b.go" {
+				return errFragile
+			}
+			a.ReportFile(a.FilePath, "fragile")
+			return nil
+		},
+	}
+	functions := &Plugin{
+		Name:       "functions",
+		Extensions: []string{"go"},
+		Run: func(a *Analysis) error {
+			for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+				a.Report(n, "function")
+			}
+			return nil
+		},
+	}
+	opts := RunOptions{Options: Options{Jobs: 2}, Plugins: []*Plugin{fragile, functions}, Paths: []string{root}}
+
+	_, err := Run(context.Background(), opts)
+	var runErr *RunError
+	if !errors.As(err, &runErr) || len(runErr.Errors) != 2 || !errors.Is(err, errFragile) {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	for i, name := range []string{"a.go", "c.go"} {
+		failure := runErr.Errors[i]
+		if failure.Path != filepath.Join(root, name) || failure.Plugin != "fragile" {
+			fmt.Printf("runErr.Errors[%d]: %#v\n", i, failure)
+			t.Fail()
+		}
+	}
+	exp := fmt.Sprintf("[fragile] unable to check file %s: can't handle this", filepath.Join(root, "a.go"))
+	if runErr.Errors[0].Error() != exp {
+		fmt.Printf("runErr.Errors[0]: %v\n", runErr.Errors[0])
+		t.Fail()
+	}
+
+	opts.KeepGoing = true
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	tags := []string{}
+	for _, v := range report.Violations() {
+		tags = append(tags, fmt.Sprintf("%s:%s", filepath.Base(v.FilePath), v.tag()))
+	}
+	if fmt.Sprint(tags) != "[a.go:functions a.go:internal/error b.go:fragile b.go:functions c.go:functions c.go:internal/error]" {
+		fmt.Printf("tags: %v\n", tags)
+		t.Fail()
+	}
+
+	opts.Content = map[string][]byte{filepath.Join(root, "unsaved.go"): []byte("package foo\n\nfunc unsaved() {}\n")}
+	opts.Paths = []string{filepath.Join(root, "unsaved.go")}
+	opts.Plugins = []*Plugin{functions}
+	report, err = Run(context.Background(), opts)
+	if err != nil || len(report.Violations()) != 1 {
+		fmt.Printf("report: %v %v\n", report, err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Paths = []string{root}
+	_, err = Run(ctx, opts)
+	if !errors.Is(err, context.Canceled) {
+		fmt.Printf("err: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestPluginIsolation(t *testing.T) {
+	root := t.TempDir()
+	writeTestTree(t, root, map[string]string{
+		"a.go": "package foo\n\nfunc a() {}\n",
+		"b.go": "package foo\n\nfunc b() {}\n",
+	})
+	release := make(chan struct{})
+	defer close(release)
+	plugins := []*Plugin{
+		{
+			Name:       "panicky",
+			Extensions: []string{"go"},
+			Run: func(a *Analysis) error {
+				var n *sitter.Node
+				a.Report(n.Parent(), "unreachable")
+				return nil
+			},
+			Finalize: func(a *Analysis) error {
+				panic("finalize")
+			},
+		},
+		{
+			Name:       "slow",
+			Extensions: []string{"go"},
+			Run: func(a *Analysis) error {
+				<-a.Context().Done()
+				return a.Context().Err()
+			},
+		},
+		{
+			Name:       "hanging",
+			Extensions: []string{"go"},
+			Serial:     true,
+			Run: func(a *Analysis) error {
+				<-release
+				return nil
+			},
+			Finalize: func(a *Analysis) error {
+				return fmt.Errorf("finalize ran while a call is still running")
+			},
+		},
+		{
+			Name:       "functions",
+			Extensions: []string{"go"},
+			Run: func(a *Analysis) error {
+				for _, n := range FindNamedNodes(a.Root, "function_declaration") {
+					a.Report(n, "function")
+				}
+				return nil
+			},
+		},
+	}
+
+	report, err := Run(context.Background(), RunOptions{Options: Options{Jobs: 1, Timeout: 50 * time.Millisecond}, Plugins: plugins, Paths: []string{root}})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	tags := []string{}
+	for _, v := range report.Violations() {
+		tags = append(tags, fmt.Sprintf("%s:%s", filepath.Base(v.FilePath), v.tag()))
+	}
+	exp := "[a.go:functions a.go:internal/panic a.go:internal/timeout a.go:internal/timeout b.go:functions b.go:internal/panic b.go:internal/timeout b.go:internal/timeout .:internal/panic .:internal/timeout]"
+	if fmt.Sprint(tags) != exp {
+		fmt.Printf("tags: %v\n", tags)
+		t.FailNow()
+	}
+
+	violations := report.Violations()
+	if !strings.HasPrefix(violations[1].Message, "[panicky] unable to check file "+filepath.Join(root, "a.go")+": panic: runtime error: invalid memory address or nil pointer dereference\n") || !strings.Contains(violations[1].Message, "goroutine") {
+		fmt.Printf("violations[1]: %v\n", violations[1].Message)
+		t.Fail()
+	}
+	if violations[2].Message != "[slow] unable to check file "+filepath.Join(root, "a.go")+": plugin timed out after 50ms" {
+		fmt.Printf("violations[2]: %v\n", violations[2].Message)
+		t.Fail()
+	}
+	if violations[7].Message != "[hanging] unable to check file "+filepath.Join(root, "b.go")+": plugin timed out, an earlier call is still running" {
+		fmt.Printf("violations[7]: %v\n", violations[7].Message)
+		t.Fail()
+	}
+	if !strings.HasPrefix(violations[8].Message, "[panicky] unable to finalize: panic: finalize\n") {
+		fmt.Printf("violations[8]: %v\n", violations[8].Message)
+		t.Fail()
+	}
+	if violations[9].Message != "[hanging] unable to finalize: plugin timed out, an earlier call is still running" {
+		fmt.Printf("violations[9]: %v\n", violations[9].Message)
+		t.Fail()
+	}
+}
+
+func TestPluginCallsRearm(t *testing.T) {
+	calls := newPluginCalls(&Plugin{Serial: true})
+	release := make(chan struct{})
+	hanging := func(a *Analysis) error {
+		<-release
+		return nil
+	}
+	quick := func(a *Analysis) error {
+		return nil
+	}
+	timeout := 20 * time.Millisecond
+
+	err := callPlugin(context.Background(), hanging, &Analysis{}, timeout, calls)
+	if !errors.Is(err, ErrTimeout) {
+		fmt.Printf("hanging: %v\n", err)
+		t.FailNow()
+	}
+	err = callPlugin(context.Background(), quick, &Analysis{}, timeout, calls)
+	if err == nil || err.Error() != "plugin timed out, an earlier call is still running" {
+		fmt.Printf("while hanging: %v\n", err)
+		t.Fail()
+	}
+	if !calls.stuck() {
+		t.Fail()
+	}
+
+	// once the abandoned call returned, calls wait for each other again
+	close(release)
+	for i := 0; i < 100; i++ {
+		err = callPlugin(context.Background(), quick, &Analysis{}, timeout, calls)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		fmt.Printf("after release: %v\n", err)
+		t.FailNow()
+	}
+	err = callPlugin(context.Background(), quick, &Analysis{}, timeout, calls)
+	if err != nil {
+		fmt.Printf("again: %v\n", err)
+		t.Fail()
+	}
+	if calls.stuck() {
+		t.Fail()
+	}
+}
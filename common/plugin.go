@@ -4,10 +4,41 @@ type Plugin struct {
 	Name       string
 	Doc        string
 	Extensions []string
-	Run        func(analysis *Analysis) error
-	Finalize   func(analysis *Analysis) error
+
+	// If ErrorCodes isn't empty, justifications for other error codes of this plugin are reported as unknown
+	ErrorCodes []string
+
+	// Severity is the level of all violations of this plugin, Severities overrides it per error code.
+	// The configuration can override both.
+	Severity   Severity
+	Severities map[string]Severity
+
+	Run      func(analysis *Analysis) error
+	Finalize func(analysis *Analysis) error
+
+	// Version is part of the key of the cache, plugins without a version are never cached.
+	// It has to change whenever the plugin reports different violations for the same file and configuration.
+	Version string
+
+	// Restore is called instead of Run for files whose violations are taken from the cache, with the state Run passed to Analysis.Persist.
+	// Plugins with Finalize are only cached if they set Restore, so they know about every file.
+	Restore func(analysis *Analysis, state []byte) error
+
+	// FactsOnly tells that Finalize only uses facts recorded with RecordFact, which are cached as well, so the plugin is cached without Restore
+	FactsOnly bool
+
+	// Run isn't called for files with syntax errors if SkipSyntaxErrors is set, they are reported by the plugin syntax anyway
+	SkipSyntaxErrors bool
+
+	// Run is called for multiple files concurrently unless Serial is set.
+	// Plugins that keep state across files without synchronizing it themselves have to set Serial.
+	// Finalize is called once after every call to Run has returned, it's skipped and reported as a timeout while a call that timed out is still running.
+	Serial bool
 }
 
+// builtinPlugins are part of every run, their names are reserved
+var builtinPlugins = []*Plugin{justificationPlugin, internalPlugin, syntaxPlugin}
+
 func (p *Plugin) handlesExtension(ext string) bool {
 	for _, e := range p.Extensions {
 		if e == ext {
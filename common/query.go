@@ -1,6 +1,14 @@
 package common
 
-import sitter "github.com/smacker/go-tree-sitter"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
 
 func FindNamedNodes(n *sitter.Node, name string) []*sitter.Node {
 	results := []*sitter.Node{}
@@ -13,3 +21,213 @@ func FindNamedNodes(n *sitter.Node, name string) []*sitter.Node {
 	}
 	return results
 }
+
+// A Query is a tree-sitter S-expression query, see https://tree-sitter.github.io/tree-sitter/using-parsers#query-syntax
+// It is compiled once for every language it's used with, so it should be created once per plugin and not per file.
+// The predicates #eq?, #not-eq?, #match?, #not-match?, #any-of? and #not-any-of? are supported.
+type Query struct {
+	Source string
+
+	mutex    sync.Mutex
+	compiled map[*sitter.Language]*compiledQuery
+}
+
+type compiledQuery struct {
+	query      *sitter.Query
+	predicates [][]queryPredicate // indexed by pattern
+}
+
+type queryPredicate struct {
+	operator     string
+	capture      string
+	values       []string
+	valueCapture string
+	regex        *regexp.Regexp
+}
+
+// A Match is a single match of one of the patterns in a Query
+type Match struct {
+	PatternIndex int
+
+	captures map[string][]*sitter.Node
+}
+
+func NewQuery(source string) *Query {
+	return &Query{Source: source}
+}
+
+// Capture returns the first node captured with the given name, nil if there isn't any
+func (m Match) Capture(name string) *sitter.Node {
+	nodes := m.captures[name]
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// CaptureAll returns all nodes captured with the given name, a quantified capture can contain several
+func (m Match) CaptureAll(name string) []*sitter.Node {
+	return m.captures[name]
+}
+
+func (q *Query) compile(lang *sitter.Language) (*compiledQuery, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	cq, found := q.compiled[lang]
+	if found {
+		return cq, nil
+	}
+
+	query, err := sitter.NewQuery([]byte(q.Source), lang)
+	if err != nil {
+		return nil, err
+	}
+	cq = &compiledQuery{query: query}
+	for i := uint32(0); i < query.PatternCount(); i++ {
+		predicates := []queryPredicate{}
+		for _, steps := range query.PredicatesForPattern(i) {
+			p, err := newQueryPredicate(query, steps)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil {
+				predicates = append(predicates, *p)
+			}
+		}
+		cq.predicates = append(cq.predicates, predicates)
+	}
+
+	if q.compiled == nil {
+		q.compiled = map[*sitter.Language]*compiledQuery{}
+	}
+	q.compiled[lang] = cq
+	return cq, nil
+}
+
+func newQueryPredicate(query *sitter.Query, steps []sitter.QueryPredicateStep) (*queryPredicate, error) {
+	// the last step always has the type QueryPredicateStepTypeDone
+	if len(steps) < 2 || steps[0].Type != sitter.QueryPredicateStepTypeString {
+		return nil, errors.New("predicate must begin with a literal value")
+	}
+	operator := query.StringValueForId(steps[0].ValueId)
+	args := steps[1 : len(steps)-1]
+
+	switch operator {
+	case "set!", "is?", "is-not?":
+		// these are directives for syntax highlighting, they don't influence matching
+		return nil, nil
+	case "eq?", "not-eq?", "match?", "not-match?", "any-of?", "not-any-of?":
+	default:
+		return nil, fmt.Errorf("unknown predicate #%s", operator)
+	}
+
+	if len(args) < 2 || args[0].Type != sitter.QueryPredicateStepTypeCapture {
+		return nil, fmt.Errorf("predicate #%s needs a capture followed by its arguments", operator)
+	}
+	p := &queryPredicate{
+		operator: operator,
+		capture:  query.CaptureNameForId(args[0].ValueId),
+	}
+	for _, arg := range args[1:] {
+		if arg.Type == sitter.QueryPredicateStepTypeCapture {
+			if operator != "eq?" && operator != "not-eq?" || len(args) != 2 {
+				return nil, fmt.Errorf("predicate #%s can't compare against a capture", operator)
+			}
+			p.valueCapture = query.CaptureNameForId(arg.ValueId)
+		} else {
+			p.values = append(p.values, query.StringValueForId(arg.ValueId))
+		}
+	}
+
+	if operator == "match?" || operator == "not-match?" {
+		if len(p.values) != 1 {
+			return nil, fmt.Errorf("predicate #%s needs exactly one regular expression", operator)
+		}
+		regex, err := regexp.Compile(p.values[0])
+		if err != nil {
+			return nil, fmt.Errorf("predicate #%s has invalid regular expression: %s", operator, err)
+		}
+		p.regex = regex
+	}
+	return p, nil
+}
+
+func (p queryPredicate) matches(captures map[string][]*sitter.Node, content []byte) bool {
+	positive := true
+	switch p.operator {
+	case "not-eq?", "not-match?", "not-any-of?":
+		positive = false
+	}
+
+	for _, n := range captures[p.capture] {
+		text := n.Content(content)
+		var result bool
+		switch p.operator {
+		case "eq?", "not-eq?":
+			if p.valueCapture != "" {
+				others := captures[p.valueCapture]
+				result = len(others) > 0 && others[0].Content(content) == text
+			} else {
+				result = len(p.values) == 1 && p.values[0] == text
+			}
+		case "match?", "not-match?":
+			result = p.regex.MatchString(text)
+		case "any-of?", "not-any-of?":
+			for _, v := range p.values {
+				if v == text {
+					result = true
+					break
+				}
+			}
+		}
+		if result != positive {
+			return false
+		}
+	}
+	return true
+}
+
+func runQuery(ctx context.Context, q *Query, lang *sitter.Language, root *sitter.Node, content []byte) ([]Match, error) {
+	cq, err := q.compile(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(cq.query, root)
+
+	matches := []Match{}
+	for {
+		// big files can have a lot of matches, a plugin that ran out of time stops here
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		qm, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+
+		m := Match{
+			PatternIndex: int(qm.PatternIndex),
+			captures:     map[string][]*sitter.Node{},
+		}
+		for _, c := range qm.Captures {
+			name := cq.query.CaptureNameForId(c.Index)
+			m.captures[name] = append(m.captures[name], c.Node)
+		}
+
+		matched := true
+		for _, p := range cq.predicates[qm.PatternIndex] {
+			if !p.matches(m.captures, content) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
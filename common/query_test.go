@@ -0,0 +1,113 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func findMatches(t *testing.T, code string, ext string, query string) []Match {
+	content := []byte(code)
+	root, err := parseFileContent(content, ext)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	a := &Analysis{Content: content, Root: root, Extension: ext}
+	matches, err := a.FindMatches(NewQuery(query))
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	return matches
+}
+
+func checkMatchedNames(t *testing.T, code string, ext string, query string, exp []string) {
+	matches := findMatches(t, code, ext, query)
+	act := []string{}
+	for _, m := range matches {
+		act = append(act, m.Capture("name").Content([]byte(code)))
+	}
+	if fmt.Sprint(exp) != fmt.Sprint(act) {
+		fmt.Printf("query: %s\n", query)
+		fmt.Printf("exp: %#v\n", exp)
+		fmt.Printf("act: %#v\n", act)
+		t.FailNow()
+	}
+}
+
+func TestFindMatches(t *testing.T) {
+	code := "package foo\n\nfunc foo() {}\nfunc bar() {}\nfunc fooBar() {}\n"
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name)`, []string{"foo", "bar", "fooBar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#eq? @name "bar"))`, []string{"bar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#not-eq? @name "bar"))`, []string{"foo", "fooBar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#match? @name "^foo"))`, []string{"foo", "fooBar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#not-match? @name "Bar$"))`, []string{"foo", "bar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#any-of? @name "foo" "fooBar"))`, []string{"foo", "fooBar"})
+	checkMatchedNames(t, code, "go", `(function_declaration name: (identifier) @name (#not-any-of? @name "foo" "fooBar"))`, []string{"bar"})
+
+	code = "package foo\n\nvar a = a\nvar b = c\n"
+	checkMatchedNames(t, code, "go", `(var_spec name: (identifier) @name value: (expression_list (identifier) @value) (#eq? @name @value))`, []string{"a"})
+
+	code = "def foo():\n    a\n    b\n    c\n"
+	matches := findMatches(t, code, "py", `(block (expression_statement)+ @stmt)`)
+	if len(matches) != 1 || len(matches[0].CaptureAll("stmt")) != 3 || matches[0].Capture("missing") != nil {
+		t.FailNow()
+	}
+}
+
+func TestFindMatchesErrors(t *testing.T) {
+	content := []byte("package foo\n")
+	root, err := parseFileContent(content, "go")
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	a := &Analysis{Content: content, Root: root, Extension: "go"}
+	queries := []string{
+		`(function_declaration`,
+		`(unknown_node_type)`,
+		`((identifier) @name (#foo? @name "bar"))`,
+		`((identifier) @name (#match? @name "("))`,
+		`((identifier) @name (#any-of? @name @name))`,
+	}
+	for _, query := range queries {
+		_, err := a.FindMatches(NewQuery(query))
+		if err == nil {
+			fmt.Printf("query: %s\n", query)
+			t.Fail()
+		}
+	}
+
+	_, err = (&Analysis{}).FindMatches(NewQuery(`(identifier)`))
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestReportCapture(t *testing.T) {
+	content := []byte("package foo\n\n// JUSTIFY(test/E001): ok\nfunc foo() {}\nfunc bar() {}\n")
+	root, err := parseFileContent(content, "go")
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	a := &Analysis{Content: content, Root: root, FilePath: "foo.go", Extension: "go", pluginName: "test"}
+	matches, err := a.FindMatches(NewQuery(`(function_declaration name: (identifier) @name) @func`))
+	if err != nil || len(matches) != 2 {
+		t.FailNow()
+	}
+	for _, m := range matches {
+		a.ReportCaptureCodef(m, "func", "E001", "found %s", m.Capture("name").Content(content))
+	}
+	if len(a.violations) != 2 {
+		t.FailNow()
+	}
+	if a.violations[0].Message != "found foo" || a.violations[0].StartLine != 3 || a.violations[0].Justification == nil {
+		fmt.Printf("a.violations[0]: %v\n", a.violations[0])
+		t.Fail()
+	}
+	if a.violations[1].Message != "found bar" || a.violations[1].StartLine != 4 || a.violations[1].Justification != nil {
+		fmt.Printf("a.violations[1]: %v\n", a.violations[1])
+		t.Fail()
+	}
+}
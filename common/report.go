@@ -9,6 +9,52 @@ import (
 
 type Report struct {
 	violations []Violation
+
+	// plugins are used for rule metadata in SARIF output
+	plugins []*Plugin
+
+	warnings []string
+}
+
+// Violations are all violations found in the run, including justified ones
+func (r Report) Violations() []Violation {
+	return r.violations
+}
+
+// Warnings are problems that didn't stop the run, e.g. plugins in the configuration that aren't known to this binary
+func (r Report) Warnings() []string {
+	return r.warnings
+}
+
+// exitCode of Main for the report, errors of the run win over violations and problems with justifications alone have their own code
+func (r Report) exitCode(threshold Severity) int {
+	internal := false
+	unjustified := false
+	justification := false
+	for _, vio := range r.violations {
+		// failures collected with -keep-going, panics and timeouts are errors of the run
+		if vio.PluginName == internalPlugin.Name {
+			internal = true
+		}
+		if vio.Justification != nil || !vio.Severity.AtLeast(threshold) {
+			continue
+		}
+		if vio.PluginName == justificationPlugin.Name {
+			justification = true
+		} else {
+			unjustified = true
+		}
+	}
+	if internal {
+		return 2
+	}
+	if unjustified {
+		return 1
+	}
+	if justification {
+		return 3
+	}
+	return 0
 }
 
 func (r Report) MarshalJSON() ([]byte, error) {
@@ -27,10 +73,16 @@ func (r Report) WriteCsv(parentWriter io.Writer) error {
 	w := csv.NewWriter(parentWriter)
 	for _, vio := range r.violations {
 		just := ""
+		ticket := ""
+		until := ""
 		if vio.Justification != nil {
 			just = vio.Justification.Message
+			ticket = vio.Justification.Ticket
+			if !vio.Justification.Until.IsZero() {
+				until = vio.Justification.Until.Format(justificationDateFormat)
+			}
 		}
-		records := []string{vio.PluginName, vio.FilePath, fmt.Sprintf("%d", vio.StartLine), fmt.Sprintf("%d", vio.StartColumn), fmt.Sprintf("%d", vio.EndLine), fmt.Sprintf("%d", vio.EndColumn), vio.ErrorCode, vio.Message, just}
+		records := []string{vio.PluginName, vio.FilePath, fmt.Sprintf("%d", vio.StartLine), fmt.Sprintf("%d", vio.StartColumn), fmt.Sprintf("%d", vio.EndLine), fmt.Sprintf("%d", vio.EndColumn), vio.ErrorCode, vio.Message, just, ticket, until, vio.Severity.String()}
 		err := w.Write(records)
 		if err != nil {
 			return err
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE: JSON is a subset of YAML, so rule files can be written in either format
+type ruleFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// A Rule is a plugin defined by a single tree-sitter query instead of Go code
+type Rule struct {
+	Name       string   `yaml:"name"`
+	Doc        string   `yaml:"doc"`
+	Extensions []string `yaml:"extensions"`
+	ErrorCode  string   `yaml:"code"`
+	Severity   Severity `yaml:"severity"`
+
+	// Message is a text/template, the text of every capture of the match is available by its name, e.g. {{.name}}
+	Message string `yaml:"message"`
+	Query   string `yaml:"query"`
+
+	// Capture is the name of the capture that is reported, "match" by default
+	Capture string `yaml:"capture"`
+}
+
+// LoadRules reads a YAML or JSON rule file and turns every rule in it into a plugin
+func LoadRules(path string) ([]*Plugin, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rule file %s: %s", path, err)
+	}
+	var rf ruleFile
+	err = yaml.Unmarshal(content, &rf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rule file %s: %s", path, err)
+	}
+
+	plugins := []*Plugin{}
+	for i, rule := range rf.Rules {
+		plugin, err := rule.Plugin()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d in rule file %s: %s", i+1, path, err)
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// Plugin checks the rule and builds a plugin from it, the query is compiled for every extension up front
+func (r Rule) Plugin() (*Plugin, error) {
+	if r.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if strings.ContainsAny(r.Name, "/,()") {
+		return nil, fmt.Errorf("rule %s: name must not contain any of / , ( )", r.Name)
+	}
+	if len(r.Extensions) == 0 {
+		return nil, fmt.Errorf("rule %s: missing extensions", r.Name)
+	}
+	if r.Query == "" {
+		return nil, fmt.Errorf("rule %s: missing query", r.Name)
+	}
+	capture := r.Capture
+	if capture == "" {
+		capture = "match"
+	}
+	msg, err := template.New(r.Name).Option("missingkey=zero").Parse(r.Message)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: invalid message: %s", r.Name, err)
+	}
+
+	query := NewQuery(r.Query)
+	for _, ext := range r.Extensions {
+		lang := getLanguage(ext)
+		if lang == nil {
+			return nil, fmt.Errorf("rule %s: unknown extension %s", r.Name, ext)
+		}
+		cq, err := query.compile(lang)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid query for extension %s: %s", r.Name, ext, err)
+		}
+		found := false
+		for i := uint32(0); i < cq.query.CaptureCount(); i++ {
+			if cq.query.CaptureNameForId(i) == capture {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("rule %s: query has no capture @%s", r.Name, capture)
+		}
+	}
+
+	run := func(a *Analysis) error {
+		matches, err := a.FindMatches(query)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			data := map[string]string{}
+			for name, nodes := range m.captures {
+				data[name] = nodes[0].Content(a.Content)
+			}
+			var sb strings.Builder
+			err := msg.Execute(&sb, data)
+			if err != nil {
+				return fmt.Errorf("unable to build message: %s", err)
+			}
+			a.ReportCaptureCode(m, capture, r.ErrorCode, sb.String())
+		}
+		return nil
+	}
+
+	// the rule is all there is to the plugin, so a changed rule is a new version
+	definition, err := yaml.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %s", r.Name, err)
+	}
+	plugin := &Plugin{
+		Name:       r.Name,
+		Doc:        r.Doc,
+		Extensions: r.Extensions,
+		Severity:   r.Severity,
+		Version:    hashContent(definition),
+		Run:        run,
+	}
+	if r.ErrorCode != "" {
+		plugin.ErrorCodes = []string{r.ErrorCode}
+	}
+	return plugin, nil
+}
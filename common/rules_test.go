@@ -0,0 +1,87 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	rules := `{"rules": [{
+		"name": "no-init",
+		"doc": "reports init functions",
+		"extensions": ["go"],
+		"code": "E001",
+		"severity": "warning",
+		"message": "{{.name}} function in {{.missing}}package",
+		"capture": "func",
+		"query": "(function_declaration name: (identifier) @name (#eq? @name \"init\")) @func"
+	}]}`
+	err := os.WriteFile(rulesPath, []byte(rules), 0o644)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	code := "package foo\n\nfunc init() {}\n\n// JUSTIFY(no-init/E001): needed\nfunc init() {}\n\nfunc main() {}\n"
+	err = os.WriteFile(filepath.Join(dir, "foo.go"), []byte(code), 0o644)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+
+	plugins, err := LoadRules(rulesPath)
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(plugins) != 1 || plugins[0].Name != "no-init" || plugins[0].Doc != "reports init functions" {
+		t.FailNow()
+	}
+	violations, err := RunChecksForDirectories(plugins, []string{dir})
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	if len(violations) != 2 {
+		fmt.Printf("len(violations): %d\n", len(violations))
+		t.FailNow()
+	}
+	for i, v := range violations {
+		if v.Message != "init function in package" || v.ErrorCode != "E001" || v.Severity != SeverityWarning || v.StartLine != uint32(2+3*i) {
+			fmt.Printf("violations[%d]: %v\n", i, v)
+			t.Fail()
+		}
+	}
+	if violations[0].Justification != nil || violations[1].Justification == nil {
+		t.Fail()
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	rules := []Rule{
+		{Extensions: []string{"go"}, Query: "(identifier) @match"},
+		{Name: "a/b", Extensions: []string{"go"}, Query: "(identifier) @match"},
+		{Name: "foo", Query: "(identifier) @match"},
+		{Name: "foo", Extensions: []string{"go"}},
+		{Name: "foo", Extensions: []string{"unknown"}, Query: "(identifier) @match"},
+		{Name: "foo", Extensions: []string{"go"}, Query: "(identifier"},
+		{Name: "foo", Extensions: []string{"go", "py"}, Query: "(function_declaration) @match"},
+		{Name: "foo", Extensions: []string{"go"}, Query: "(identifier) @name"},
+		{Name: "foo", Extensions: []string{"go"}, Query: "(identifier) @match", Message: "{{"},
+	}
+	for i, r := range rules {
+		_, err := r.Plugin()
+		if err == nil {
+			fmt.Printf("rules[%d] is valid\n", i)
+			t.Fail()
+		}
+	}
+
+	_, err := LoadRules(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Fail()
+	}
+}
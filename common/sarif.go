@@ -0,0 +1,252 @@
+package common
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// NOTE: this follows https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const sarifSchema = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations,omitempty"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+	Fixes        []sarifFix         `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+	ContextRegion    *sarifRegion          `json:"contextRegion,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// all these are 1-indexed
+type sarifRegion struct {
+	StartLine   uint32        `json:"startLine"`
+	StartColumn uint32        `json:"startColumn,omitempty"`
+	EndLine     uint32        `json:"endLine"`
+	EndColumn   uint32        `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+type sarifFix struct {
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifByteRegion `json:"deletedRegion"`
+	InsertedContent *sarifMessage   `json:"insertedContent,omitempty"`
+}
+
+// edits are given in bytes, which unlike lines and columns doesn't need the content of the file
+type sarifByteRegion struct {
+	ByteOffset uint32 `json:"byteOffset"`
+	ByteLength uint32 `json:"byteLength"`
+}
+
+type sarifSuppression struct {
+	Kind          string            `json:"kind"`
+	Justification string            `json:"justification"`
+	Properties    map[string]string `json:"properties,omitempty"`
+}
+
+func (r Report) WriteSarif(w io.Writer) error {
+	docs := map[string]string{}
+	ruleIDs := []string{}
+	for _, plugin := range r.plugins {
+		docs[plugin.Name] = plugin.Doc
+		ruleIDs = append(ruleIDs, plugin.Name)
+	}
+	// error codes are only known once they are reported, every combination of plugin and error code is a rule of its own
+	codeRuleIDs := []string{}
+	for _, vio := range r.violations {
+		id := vio.tag()
+		_, found := docs[id]
+		if !found {
+			docs[id] = docs[vio.PluginName]
+			codeRuleIDs = append(codeRuleIDs, id)
+		}
+	}
+	sort.Strings(codeRuleIDs)
+	ruleIDs = append(ruleIDs, codeRuleIDs...)
+
+	rules := []sarifRule{}
+	ruleIndices := map[string]int{}
+	for i, id := range ruleIDs {
+		rule := sarifRule{ID: id}
+		if docs[id] != "" {
+			rule.ShortDescription = &sarifMessage{Text: docs[id]}
+		}
+		rules = append(rules, rule)
+		ruleIndices[id] = i
+	}
+
+	results := []sarifResult{}
+	for _, vio := range r.violations {
+		result := sarifResult{
+			RuleID:    vio.tag(),
+			RuleIndex: ruleIndices[vio.tag()],
+			Level:     vio.Severity.sarifLevel(),
+			Message:   sarifMessage{Text: vio.Message},
+		}
+		if vio.FilePath != "" {
+			result.Locations = []sarifLocation{{PhysicalLocation: vio.sarifPhysicalLocation()}}
+		}
+		if vio.Justification != nil {
+			suppression := sarifSuppression{Kind: "inSource", Justification: vio.Justification.Message, Properties: map[string]string{}}
+			if vio.Justification.Ticket != "" {
+				suppression.Properties["ticket"] = vio.Justification.Ticket
+			}
+			if !vio.Justification.Until.IsZero() {
+				suppression.Properties["until"] = vio.Justification.Until.Format(justificationDateFormat)
+			}
+			result.Suppressions = []sarifSuppression{suppression}
+		}
+		if len(vio.Edits) > 0 && vio.FilePath != "" {
+			change := sarifArtifactChange{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(vio.FilePath)}}
+			for _, edit := range vio.Edits {
+				replacement := sarifReplacement{DeletedRegion: sarifByteRegion{ByteOffset: edit.StartByte, ByteLength: edit.EndByte - edit.StartByte}}
+				if edit.NewText != "" {
+					replacement.InsertedContent = &sarifMessage{Text: edit.NewText}
+				}
+				change.Replacements = append(change.Replacements, replacement)
+			}
+			result.Fixes = []sarifFix{{ArtifactChanges: []sarifArtifactChange{change}}}
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "check",
+				InformationURI: "https://github.com/unnamedtiger/check",
+				Rules:          rules,
+			}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+func (v Violation) sarifPhysicalLocation() sarifPhysicalLocation {
+	loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(v.FilePath)}}
+	if v.StartLine == 0 && v.StartColumn == 0 && v.EndLine == 0 && v.EndColumn == 0 {
+		// violations reported for a whole file don't have a region
+		return loc
+	}
+
+	loc.Region = &sarifRegion{
+		StartLine:   v.StartLine + 1,
+		StartColumn: v.sarifColumn(v.StartLine, v.StartColumn),
+		EndLine:     v.EndLine + 1,
+		EndColumn:   v.sarifColumn(v.EndLine, v.EndColumn),
+	}
+	snippet := ""
+	for line := v.StartLine; line <= v.EndLine; line++ {
+		text, found := v.relevantLine(line)
+		if !found {
+			snippet = ""
+			break
+		}
+		snippet += text
+	}
+	if snippet != "" {
+		loc.Region.Snippet = &sarifMessage{Text: snippet}
+	}
+	if len(v.RelContent) > 0 {
+		loc.ContextRegion = &sarifRegion{
+			StartLine: v.RelevantContentStartLine + 1,
+			EndLine:   v.RelevantContentStartLine + uint32(len(v.RelContent)),
+			Snippet:   &sarifMessage{Text: strings.Join(v.RelContent, "")},
+		}
+	}
+	return loc
+}
+
+func (v Violation) relevantLine(line uint32) (string, bool) {
+	if line < v.RelevantContentStartLine || line-v.RelevantContentStartLine >= uint32(len(v.RelContent)) {
+		return "", false
+	}
+	return v.RelContent[line-v.RelevantContentStartLine], true
+}
+
+// sarifColumn converts a 0-indexed byte offset into a 1-indexed column counted in code points, as far as the line is known
+func (v Violation) sarifColumn(line uint32, column uint32) uint32 {
+	text, found := v.relevantLine(line)
+	if !found || int(column) > len(text) {
+		return column + 1
+	}
+	return uint32(utf8.RuneCountInString(text[:column])) + 1
+}
+
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		p := filepath.ToSlash(path)
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		u := url.URL{Scheme: "file", Path: p}
+		return u.String()
+	}
+	u := url.URL{Path: filepath.ToSlash(path)}
+	return u.String()
+}
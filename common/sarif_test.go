@@ -0,0 +1,152 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func sarifTestReport() Report {
+	plugins := []*Plugin{
+		{Name: "unwanted-imports", Doc: "reports imports and includes of unwanted packages", Extensions: []string{"go"}},
+		{Name: "languages", Extensions: []string{"go"}},
+	}
+	violations := []Violation{
+		{
+			PluginName:  "unwanted-imports",
+			FilePath:    "test/test.go",
+			StartLine:   4,
+			StartColumn: 1,
+			EndLine:     4,
+			EndColumn:   12,
+			ErrorCode:   "E001",
+			Message:     "contains unwanted import: io/ioutil",
+			Edits:       []Edit{{StartByte: 40, EndByte: 49, NewText: "os", StartLine: 4, StartColumn: 2, EndLine: 4, EndColumn: 11}},
+
+			RelevantContentStartLine: 3,
+			RelContent:               []string{"\t\"fmt\"\n", "\t\"io/ioutil\"\n", ")\n"},
+		},
+		{
+			PluginName:    "unwanted-imports",
+			FilePath:      "test/test.go",
+			StartLine:     4,
+			StartColumn:   4,
+			EndLine:       4,
+			EndColumn:     15,
+			ErrorCode:     "E001",
+			Message:       "contains unwanted import: io/ioutil",
+			Justification: &Justification{3, 7, 3, 67, "unwanted-imports/E001", "it's okay this time, I swear", "ABC-123", time.Date(2999, 12, 31, 0, 0, 0, 0, time.UTC), ScopeNode},
+
+			RelevantContentStartLine: 3,
+			RelContent:               []string{"    // JUSTIFY(unwanted-imports/E001): it's okay this time, I swear\n", "    \"io/ioutil\"\n", ")\n"},
+		},
+		{
+			PluginName:  "languages",
+			FilePath:    "test/Grüße.go",
+			StartLine:   1,
+			StartColumn: 12,
+			EndLine:     1,
+			EndColumn:   21,
+			Message:     "mentions forbidden",
+			Severity:    SeverityWarning,
+
+			RelevantContentStartLine: 0,
+			RelContent:               []string{"package foo\n", "var grüße forbidden\n"},
+		},
+		{
+			PluginName: "languages",
+			FilePath:   "test/broken.go",
+			Message:    "has syntax errors",
+			RelContent: []string{},
+		},
+		{
+			PluginName: "unwanted-imports",
+			ErrorCode:  "E002",
+			Message:    "global catastrophe",
+			RelContent: []string{},
+		},
+	}
+	return Report{violations: violations, plugins: plugins}
+}
+
+func TestSarifGolden(t *testing.T) {
+	var buf bytes.Buffer
+	err := sarifTestReport().WriteSarif(&buf)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+
+	golden := filepath.Join("testdata", "report.sarif")
+	if *updateGolden {
+		err := os.WriteFile(golden, buf.Bytes(), 0644)
+		if err != nil {
+			fmt.Printf("err: %v\n", err)
+			t.FailNow()
+		}
+	}
+	exp, err := os.ReadFile(golden)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	if string(exp) != buf.String() {
+		fmt.Printf("exp: %v\n", string(exp))
+		fmt.Printf("buf.String(): %v\n", buf.String())
+		t.Fail()
+	}
+
+	schemaFile, err := os.Open(filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	defer schemaFile.Close()
+	compiler := jsonschema.NewCompiler()
+	err = compiler.AddResource(sarifSchema, schemaFile)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	schema, err := compiler.Compile(sarifSchema)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	var doc any
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	err = schema.Validate(doc)
+	if err != nil {
+		fmt.Printf("err: %#v\n", err)
+		t.Fail()
+	}
+}
+
+func TestSarifURI(t *testing.T) {
+	cases := map[string]string{
+		"test/test.go":       "test/test.go",
+		"test/with space.go": "test/with%20space.go",
+		"/abs/test.go":       "file:///abs/test.go",
+	}
+	for path, exp := range cases {
+		result := sarifURI(path)
+		if exp != result {
+			fmt.Printf("exp: %v\n", exp)
+			fmt.Printf("result: %v\n", result)
+			t.Fail()
+		}
+	}
+}
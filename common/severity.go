@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the level of a violation, lower values are more severe.
+// The zero value is SeverityError, so plugins report errors unless they say otherwise.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+var severityNames = []string{"error", "warning", "info", "hint"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity accepts the names error, warning, info and hint
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityError, fmt.Errorf("unknown severity %s, use one of error, warning, info or hint", name)
+}
+
+// AtLeast reports whether s is as severe as the threshold or more severe
+func (s Severity) AtLeast(threshold Severity) bool {
+	return s <= threshold
+}
+
+func (s *Severity) UnmarshalYAML(node *yaml.Node) error {
+	var name string
+	err := node.Decode(&name)
+	if err != nil {
+		return err
+	}
+	*s, err = ParseSeverity(name)
+	return err
+}
+
+// lspSeverity is the DiagnosticSeverity of the language server protocol, it starts at 1 for errors
+func (s Severity) lspSeverity() int {
+	return int(s) + 1
+}
+
+// sarifLevel maps to the levels of SARIF, which has no distinction between info and hint
+func (s Severity) sarifLevel() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// severityFor is the level of a violation of the plugin, the configuration overrides the plugin.
+// In both the error code takes precedence over the plugin as a whole.
+func severityFor(plugin *Plugin, config *Config, errorCode string) Severity {
+	severity := plugin.Severity
+	s, found := plugin.Severities[errorCode]
+	if errorCode != "" && found {
+		severity = s
+	}
+	if config == nil {
+		return severity
+	}
+	s, found = config.Severity[plugin.Name]
+	if found {
+		severity = s
+	}
+	s, found = config.Severity[plugin.Name+"/"+errorCode]
+	if errorCode != "" && found {
+		severity = s
+	}
+	return severity
+}
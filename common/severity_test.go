@@ -0,0 +1,120 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityError, SeverityWarning, SeverityInfo, SeverityHint} {
+		parsed, err := ParseSeverity(s.String())
+		if err != nil || parsed != s {
+			fmt.Printf("s: %v\n", s)
+			t.Fail()
+		}
+	}
+	_, err := ParseSeverity("fatal")
+	if err == nil {
+		t.Fail()
+	}
+	if !SeverityError.AtLeast(SeverityWarning) || !SeverityWarning.AtLeast(SeverityWarning) || SeverityInfo.AtLeast(SeverityWarning) {
+		t.Fail()
+	}
+
+	var config Config
+	err = yaml.Unmarshal([]byte("severity:\n  foo: hint\n  foo/E001: warning\n"), &config)
+	if err != nil || config.Severity["foo"] != SeverityHint || config.Severity["foo/E001"] != SeverityWarning {
+		fmt.Printf("config.Severity: %v %v\n", config.Severity, err)
+		t.Fail()
+	}
+	err = yaml.Unmarshal([]byte("severity:\n  foo: fatal\n"), &config)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestSeverityOverrides(t *testing.T) {
+	plugin := &Plugin{
+		Name:       "levels",
+		Extensions: []string{"go"},
+		Severity:   SeverityWarning,
+		Severities: map[string]Severity{"L002": SeverityInfo},
+		Run: func(a *Analysis) error {
+			for _, code := range []string{"L001", "L002", "L003"} {
+				a.ReportCode(a.Root, code, "level")
+			}
+			return nil
+		},
+	}
+	content := []byte("package foo\n")
+	check := func(config *Config, exp string) {
+		violations, err := RunChecksForContent([]*Plugin{plugin}, "a.go", content, Options{Config: config})
+		if err != nil {
+			fmt.Println(err)
+			t.FailNow()
+		}
+		severities := []string{}
+		for _, v := range violations {
+			severities = append(severities, v.Severity.String())
+		}
+		if fmt.Sprint(severities) != exp {
+			fmt.Printf("exp: %v\n", exp)
+			fmt.Printf("severities: %v\n", severities)
+			t.Fail()
+		}
+	}
+	check(nil, "[warning info warning]")
+	check(&Config{Severity: map[string]Severity{"levels": SeverityHint}}, "[hint hint hint]")
+	check(&Config{Severity: map[string]Severity{"levels": SeverityHint, "levels/L002": SeverityError}}, "[hint error hint]")
+
+	report, err := Run(context.Background(), RunOptions{Options: Options{Config: &Config{Severity: map[string]Severity{"unknown/L001": SeverityHint}}}, Plugins: []*Plugin{plugin}, Paths: []string{"a.go"}, Content: map[string][]byte{"a.go": content}})
+	if err != nil || len(report.Warnings()) != 1 {
+		fmt.Printf("unknown plugin: %v %v\n", err, report)
+		t.Fail()
+	}
+
+	v := Violation{PluginName: "levels", Message: "level", Severity: SeverityWarning, RelContent: []string{}}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"message":"level","range":{"end":{"character":0,"line":0},"start":{"character":0,"line":0}},"severity":2,"source":"levels"}` {
+		fmt.Printf("data: %s\n", data)
+		t.Fail()
+	}
+	if v.String() != "warning(levels): level\n" {
+		fmt.Printf("v.String(): %v\n", v.String())
+		t.Fail()
+	}
+	v.Severity = SeverityHint
+	v.Justification = &Justification{Tag: "levels", Message: "fine"}
+	data, err = json.Marshal(v)
+	if err != nil || string(data) != `{"message":"level","range":{"end":{"character":0,"line":0},"start":{"character":0,"line":0}},"severity":4,"source":"levels"}` {
+		fmt.Printf("data: %s\n", data)
+		t.Fail()
+	}
+}
+
+func TestReportExitCode(t *testing.T) {
+	unjustified := Violation{PluginName: "levels", Severity: SeverityError}
+	justified := Violation{PluginName: "levels", Severity: SeverityError, Justification: &Justification{Tag: "levels", Message: "fine"}}
+	warning := Violation{PluginName: "levels", Severity: SeverityWarning}
+	unused := Violation{PluginName: justificationPlugin.Name, ErrorCode: "unused"}
+	internal := Violation{PluginName: internalPlugin.Name, ErrorCode: "timeout"}
+	check := func(exp int, threshold Severity, violations ...Violation) {
+		act := Report{violations: violations}.exitCode(threshold)
+		if act != exp {
+			fmt.Printf("exit code: %d, expected %d for %v\n", act, exp, violations)
+			t.Fail()
+		}
+	}
+	check(0, SeverityError)
+	check(0, SeverityError, justified, warning)
+	check(1, SeverityWarning, justified, warning)
+	check(1, SeverityError, unused, unjustified)
+	check(3, SeverityError, justified, unused)
+	// errors of the run win, no matter which violations come first
+	check(2, SeverityError, unjustified, unused, internal)
+	check(2, SeverityError, internal, unjustified)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"fmt"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// how files with syntax errors are handled, see Options.SyntaxErrors
+const (
+	SyntaxErrorsError   = "error"
+	SyntaxErrorsWarning = "warning"
+	SyntaxErrorsFatal   = "fatal"
+)
+
+// syntaxPlugin is the built-in checker for syntax errors, plugins still run on broken trees unless they set SkipSyntaxErrors
+var syntaxPlugin = &Plugin{
+	Name:       "syntax",
+	Doc:        "reports syntax errors and missing tokens found by the parser",
+	ErrorCodes: []string{"error", "missing"},
+}
+
+func validateSyntaxErrors(mode string) error {
+	if mode != "" && mode != SyntaxErrorsError && mode != SyntaxErrorsWarning && mode != SyntaxErrorsFatal {
+		return fmt.Errorf("unknown handling of syntax errors %s, use one of error, warning or fatal", mode)
+	}
+	return nil
+}
+
+// checkSyntax reports every region the parser couldn't make sense of, nodes inside such a region aren't reported again
+func checkSyntax(path string, content []byte, root *sitter.Node) []Violation {
+	if !root.HasError() {
+		return nil
+	}
+	result := []Violation{}
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		if n.IsError() {
+			result = append(result, newViolation(syntaxPlugin.Name, path, n, content, "error", "syntax error"))
+			return
+		}
+		if n.IsMissing() {
+			result = append(result, newViolation(syntaxPlugin.Name, path, n, content, "missing", fmt.Sprintf("syntax error: missing %s", n.Type())))
+			return
+		}
+		if !n.HasError() {
+			return
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			visit(n.Child(i))
+		}
+	}
+	visit(root)
+	return result
+}
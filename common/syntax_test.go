@@ -0,0 +1,86 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSyntaxErrors(t *testing.T) {
+	content := map[string][]byte{
+		"a.go": []byte("package foo\n\nfunc a() {\n\tfoo(1, 2\n}\n"),
+		"b.go": []byte("package foo\n\nfunc b() { @@ }\n"),
+		"c.go": []byte("package foo\n\nfunc c() {}\n"),
+	}
+	ran := map[string]bool{}
+	plugins := []*Plugin{
+		{
+			Name:             "strict",
+			Extensions:       []string{"go"},
+			SkipSyntaxErrors: true,
+			Serial:           true,
+			Run: func(a *Analysis) error {
+				ran[a.FilePath] = true
+				return nil
+			},
+		},
+	}
+	opts := RunOptions{Options: Options{Jobs: 1}, Plugins: plugins, Paths: []string{"a.go", "b.go", "c.go"}, Content: content}
+
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		fmt.Printf("err: %v\n", err)
+		t.FailNow()
+	}
+	exp := []string{
+		"a.go 3:9-3:9 syntax/missing error: syntax error: missing )",
+		"b.go 2:11-2:13 syntax/error error: syntax error",
+	}
+	vios := report.Violations()
+	if len(vios) != len(exp) {
+		fmt.Printf("vios: %v\n", vios)
+		t.FailNow()
+	}
+	for i, v := range vios {
+		act := fmt.Sprintf("%s %d:%d-%d:%d %s %s: %s", v.FilePath, v.StartLine, v.StartColumn, v.EndLine, v.EndColumn, v.tag(), v.Severity, v.Message)
+		if act != exp[i] {
+			fmt.Printf("vios[%d]: %s\n", i, act)
+			t.Fail()
+		}
+	}
+	if ran["a.go"] || ran["b.go"] || !ran["c.go"] {
+		fmt.Printf("ran: %v\n", ran)
+		t.Fail()
+	}
+
+	opts.SyntaxErrors = SyntaxErrorsWarning
+	report, err = Run(context.Background(), opts)
+	if err != nil || len(report.Violations()) != 2 || report.Violations()[0].Severity != SeverityWarning {
+		fmt.Printf("warning: %v %v\n", err, report)
+		t.Fail()
+	}
+
+	opts.Config = &Config{Disable: []string{"syntax"}}
+	report, err = Run(context.Background(), opts)
+	if err != nil || len(report.Violations()) != 0 {
+		fmt.Printf("disabled: %v %v\n", err, report)
+		t.Fail()
+	}
+
+	opts.Config = nil
+	opts.SyntaxErrors = SyntaxErrorsFatal
+	_, err = Run(context.Background(), opts)
+	var runErr *RunError
+	if !errors.As(err, &runErr) || len(runErr.Errors) != 2 || runErr.Errors[0].Error() != "unable to parse file a.go: syntax error at 4:10" {
+		fmt.Printf("fatal: %v\n", err)
+		t.Fail()
+	}
+
+	opts.SyntaxErrors = "ignore"
+	_, err = Run(context.Background(), opts)
+	if err == nil {
+		fmt.Printf("unknown mode accepted\n")
+		t.Fail()
+	}
+}
@@ -22,11 +22,21 @@ type Violation struct {
 
 	ErrorCode string
 	Message   string
+	Severity  Severity
 
 	Justification *Justification
 
+	// Edits fix the violation when applied together, they are empty if there is no automatic fix
+	Edits []Edit
+
 	RelevantContentStartLine uint32
 	RelContent               []string
+
+	// an expired justification doesn't justify the violation anymore, it's kept so it doesn't count as unused
+	expiredJustification *Justification
+
+	// set for violations of the plugin justification about an unused justification, Finalize may still use it
+	unusedJustification *Justification
 }
 
 func newViolation(pluginName string, filePath string, n *sitter.Node, content []byte, errorCode string, message string) Violation {
@@ -54,7 +64,8 @@ func newViolation(pluginName string, filePath string, n *sitter.Node, content []
 	just := findJustification(n, content, tag)
 
 	startLine := n.StartPoint().Row
-	if just != nil && startLine > just.StartLine {
+	// the output includes justifications directly before the node, others may be far away
+	if just != nil && just.Scope == ScopeNode && startLine > just.StartLine {
 		startLine = just.StartLine
 	}
 	if startLine >= relevantContentBorder {
@@ -79,9 +90,24 @@ func newViolation(pluginName string, filePath string, n *sitter.Node, content []
 		RelevantContentStartLine: startLine,
 		RelContent:               relevantContent,
 	}
+	if just != nil && just.Expired(now()) {
+		v.expireJustification()
+	}
 	return v
 }
 
+// expireJustification keeps the justification only to mark it as used and tells why the violation isn't justified
+func (v *Violation) expireJustification() {
+	just := v.Justification
+	v.Justification = nil
+	v.expiredJustification = just
+	details := "justification expired on " + just.Until.Format(justificationDateFormat)
+	if just.Ticket != "" {
+		details += ", ticket " + just.Ticket
+	}
+	v.Message += " (" + details + ")"
+}
+
 func collectContent(n *sitter.Node, content []byte, startLine uint32, endLine uint32) []string {
 	startByte := n.StartByte()
 	endByte := n.EndByte()
@@ -159,13 +185,50 @@ func (v Violation) MarshalJSON() ([]byte, error) {
 	m["source"] = v.PluginName
 	m["message"] = v.Message
 	if v.Justification != nil {
-		m["severity"] = 3 // informational
+		// justified violations are only informational
+		m["severity"] = max(v.Severity, SeverityInfo).lspSeverity()
 	} else {
-		m["severity"] = 1 // error
+		m["severity"] = v.Severity.lspSeverity()
+	}
+	data := map[string]interface{}{}
+	if v.Justification != nil && v.Justification.details() != "" {
+		just := map[string]interface{}{"message": v.Justification.Message}
+		if v.Justification.Ticket != "" {
+			just["ticket"] = v.Justification.Ticket
+		}
+		if !v.Justification.Until.IsZero() {
+			just["until"] = v.Justification.Until.Format(justificationDateFormat)
+		}
+		data["justification"] = just
+	}
+	if len(v.Edits) > 0 {
+		// the fix is kept in data which is preserved by clients for code actions, the edits are shaped like TextEdit
+		edits := []map[string]interface{}{}
+		for _, edit := range v.Edits {
+			edits = append(edits, map[string]interface{}{
+				"range": map[string]interface{}{
+					"start": map[string]uint32{"line": edit.StartLine, "character": edit.StartColumn},
+					"end":   map[string]uint32{"line": edit.EndLine, "character": edit.EndColumn},
+				},
+				"newText": edit.NewText,
+			})
+		}
+		data["edits"] = edits
+	}
+	if len(data) > 0 {
+		m["data"] = data
 	}
 	return json.Marshal(m)
 }
 
+// tag is the name used in justifications, the plugin name optionally followed by the error code
+func (v Violation) tag() string {
+	if v.ErrorCode == "" {
+		return v.PluginName
+	}
+	return v.PluginName + "/" + v.ErrorCode
+}
+
 func (v Violation) String() string {
 	return v.StringPretty(false)
 }
@@ -183,14 +246,18 @@ func (v Violation) StringPretty(color bool) string {
 		escBlue = "\x1b[94m"
 		escCyan = "\x1b[96m"
 	}
-
-	tag := v.PluginName
-	if v.ErrorCode != "" {
-		tag += "/" + v.ErrorCode
+	// unjustified violations below errors are highlighted less prominently
+	escLevel := escRed
+	if color && v.Severity == SeverityWarning {
+		escLevel = "\x1b[93m"
+	} else if color && v.Severity != SeverityError {
+		escLevel = "\x1b[92m"
 	}
+
+	tag := v.tag()
 	result := escBold
 	if v.Justification == nil {
-		result += escRed + "violation"
+		result += escLevel + v.Severity.String()
 	} else {
 		result += escCyan + "justified"
 	}
@@ -225,7 +292,7 @@ func (v Violation) StringPretty(color bool) string {
 				l := fmt.Sprintf(escBlue+"%*d | "+escReset+"", lineNumberWidth, lineNumber)
 				l += line[0:startChar]
 				if v.Justification == nil {
-					l += escRed
+					l += escLevel
 				} else {
 					l += escCyan
 				}
@@ -248,7 +315,7 @@ func (v Violation) StringPretty(color bool) string {
 					}
 				}
 				if v.Justification == nil {
-					l += escRed
+					l += escLevel
 				} else {
 					l += escCyan
 				}
@@ -261,7 +328,18 @@ func (v Violation) StringPretty(color bool) string {
 		}
 	}
 	if v.Justification != nil {
-		result += fmt.Sprintf(escBlue+"%*s = "+escReset+escBold+"justification:"+escReset+" %s\n", lineNumberWidth, "", v.Justification.Message)
+		msg := v.Justification.Message
+		if v.Justification.details() != "" {
+			msg += " (" + v.Justification.details() + ")"
+		}
+		label := "justification"
+		if v.Justification.Scope != "" && v.Justification.Scope != ScopeNode {
+			label += fmt.Sprintf(" (%s, line %d)", v.Justification.Scope, v.Justification.StartLine+1)
+		}
+		result += fmt.Sprintf(escBlue+"%*s = "+escReset+escBold+"%s:"+escReset+" %s\n", lineNumberWidth, "", label, msg)
+	}
+	if len(v.Edits) > 0 && v.Justification == nil {
+		result += fmt.Sprintf(escBlue+"%*s = "+escReset+escBold+"fix:"+escReset+" available with -fix\n", lineNumberWidth, "")
 	}
 	return result
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestViolationFormatters(t *testing.T) {
@@ -25,14 +26,14 @@ func TestViolationFormatters(t *testing.T) {
 			RelevantContentStartLine: 3,
 			RelContent:               []string{"\t\"fmt\"\n", "\t\"io/ioutil\"\n", ")\n"},
 		}
-		exp := "violation(unwanted-imports/E001): contains unwanted import: io/ioutil\n  --> test/test.go:5:2\n   |\n 4 | \t\"fmt\"\n 5 | \t\"io/ioutil\"\n   | \t^~~~~~~~~~~\n 6 | )\n"
+		exp := "error(unwanted-imports/E001): contains unwanted import: io/ioutil\n  --> test/test.go:5:2\n   |\n 4 | \t\"fmt\"\n 5 | \t\"io/ioutil\"\n   | \t^~~~~~~~~~~\n 6 | )\n"
 		if exp != v.String() {
 			fmt.Printf("exp: %v\n", exp)
 			fmt.Printf("v.String(): %v\n", v.String())
 			t.Fail()
 		}
 
-		csvExp := "unwanted-imports,test/test.go,4,1,4,12,E001,contains unwanted import: io/ioutil,\n"
+		csvExp := "unwanted-imports,test/test.go,4,1,4,12,E001,contains unwanted import: io/ioutil,,,,error\n"
 		var buf bytes.Buffer
 		r := Report{violations: []Violation{v}}
 		err := r.WriteCsv(&buf)
@@ -69,7 +70,7 @@ func TestViolationFormatters(t *testing.T) {
 			ErrorCode: "E001",
 			Message:   "contains unwanted import: io/ioutil",
 
-			Justification: &Justification{3, 7, 3, 67, "unwanted-imports/E001", "it's okay this time, I swear"},
+			Justification: &Justification{3, 7, 3, 67, "unwanted-imports/E001", "it's okay this time, I swear", "", time.Time{}, ScopeNode},
 
 			RelevantContentStartLine: 3,
 			RelContent:               []string{"    // JUSTIFY(unwanted-imports/E001): it's okay this time, I swear\n", "    \"io/ioutil\"\n", ")\n"},
@@ -81,7 +82,7 @@ func TestViolationFormatters(t *testing.T) {
 			t.Fail()
 		}
 
-		csvExp := "unwanted-imports,test/test.go,4,4,4,15,E001,contains unwanted import: io/ioutil,\"it's okay this time, I swear\"\n"
+		csvExp := "unwanted-imports,test/test.go,4,4,4,15,E001,contains unwanted import: io/ioutil,\"it's okay this time, I swear\",,,error\n"
 		var buf bytes.Buffer
 		r := Report{violations: []Violation{v}}
 		err := r.WriteCsv(&buf)
@@ -123,14 +124,14 @@ func TestViolationFormatters(t *testing.T) {
 			RelevantContentStartLine: 0,
 			RelContent:               []string{},
 		}
-		exp := "violation(unwanted-imports/E001): global catastrophe\n"
+		exp := "error(unwanted-imports/E001): global catastrophe\n"
 		if exp != v.String() {
 			fmt.Printf("exp: %v\n", exp)
 			fmt.Printf("v.String(): %v\n", v.String())
 			t.Fail()
 		}
 
-		csvExp := "unwanted-imports,,0,0,0,0,E001,global catastrophe,\n"
+		csvExp := "unwanted-imports,,0,0,0,0,E001,global catastrophe,,,,error\n"
 		var buf bytes.Buffer
 		r := Report{violations: []Violation{v}}
 		err := r.WriteCsv(&buf)
@@ -6,27 +6,76 @@ import (
 	"github.com/unnamedtiger/check/common"
 )
 
-// NOTE: If any of these packages are imported it's an instant error
-var unwanted_imports = []string{
-	"io/ioutil", // As of Go 1.16 this package is deprecated. https://pkg.go.dev/io/ioutil
+type unwantedImport struct {
+	// import path in Go, header name in C and C++
+	Path        string `yaml:"path"`
+	Code        string `yaml:"code"`
+	Reason      string `yaml:"reason"`
+	Replacement string `yaml:"replacement"`
+
+	// if set the import is rewritten to this path by -fix, only use it if the replacement is a drop-in
+	Fix string `yaml:"fix"`
+}
+
+type config struct {
+	Imports []unwantedImport `yaml:"imports"`
+}
+
+// NOTE: If any of these packages are imported it's an instant error, unless the project configures its own list
+var defaultUnwantedImports = []unwantedImport{
+	{
+		Path:        "io/ioutil",
+		Reason:      "deprecated as of Go 1.16", // https://pkg.go.dev/io/ioutil
+		Replacement: "io or os",
+	},
 }
 
+var goImportQuery = common.NewQuery(`(import_spec path: (_) @path) @import`)
+var includeQuery = common.NewQuery(`(preproc_include path: (_) @path) @import`)
+
 var Plugin = &common.Plugin{
 	Name:       "unwanted-imports",
-	Doc:        "reports imports of unwanted packages",
-	Extensions: []string{"go"},
+	Doc:        "reports imports and includes of unwanted packages",
+	Extensions: []string{"go", "c", "h", "cpp", "hpp"},
+	Version:    "1",
 	Run:        run,
 }
 
 func run(a *common.Analysis) error {
-	nodes := common.FindNamedNodes(a.Root, "import_spec")
-	for _, importSpecNode := range nodes {
-		pathNode := importSpecNode.Child(0)
-		content := pathNode.Content(a.Content)
-		content = strings.Trim(content, "\"")
-		for _, unwanted := range unwanted_imports {
-			if content == unwanted {
-				a.Reportf(importSpecNode, "contains unwanted import: %s", unwanted)
+	cfg := config{Imports: defaultUnwantedImports}
+	err := a.DecodeConfig(&cfg)
+	if err != nil {
+		return err
+	}
+
+	query := includeQuery
+	if a.Extension == "go" {
+		query = goImportQuery
+	}
+	matches, err := a.FindMatches(query)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		path := m.Capture("path")
+		content := path.Content(a.Content)
+		content = strings.Trim(content, "\"`<>")
+		for _, unwanted := range cfg.Imports {
+			if content == unwanted.Path {
+				msg := "contains unwanted import: " + unwanted.Path
+				if unwanted.Reason != "" {
+					msg += " (" + unwanted.Reason + ")"
+				}
+				if unwanted.Replacement != "" {
+					msg += ", use " + unwanted.Replacement + " instead"
+				}
+				if unwanted.Fix != "" {
+					// keeping the quotes or angle brackets around the path
+					edit := common.Edit{StartByte: path.StartByte() + 1, EndByte: path.EndByte() - 1, NewText: unwanted.Fix}
+					a.ReportCaptureCodeWithFix(m, "import", unwanted.Code, msg, edit)
+				} else {
+					a.ReportCaptureCode(m, "import", unwanted.Code, msg)
+				}
 				break
 			}
 		}
@@ -0,0 +1,8 @@
+#include <stdlib.h>
+// JUSTIFY(unwanted-imports/E003): unwanted_imports_004.c/001
+#include "legacy/api.h"
+
+int main(void) {
+    legacy_init();
+    return EXIT_SUCCESS;
+}
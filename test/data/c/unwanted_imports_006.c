@@ -0,0 +1,7 @@
+#include "legacy/api.h" // JUSTIFY(unwanted-imports/E003): unwanted_imports_006.c/001
+#include <stdlib.h>
+
+int legacy_main(void) {
+    legacy_init();
+    return EXIT_SUCCESS;
+}
@@ -0,0 +1,10 @@
+#include <cstdlib>
+// JUSTIFY(unwanted-imports/E004): unwanted_imports_005.cpp/001
+#include <stdio.h>
+#include <vector>
+
+int main() {
+    std::vector<int> values{1, 2, 3};
+    printf("%zu\n", values.size());
+    return EXIT_SUCCESS;
+}
@@ -0,0 +1,15 @@
+package foo
+
+// the justifications below are invalid on purpose, the built-in checker reports them
+
+// JUSTIFY(justification/unknown-plugin): justification_001.go/001
+// JUSTIFY(unwnated-imports): justification_001.go/typo
+func justification001() {}
+
+// JUSTIFY(justification/unused): justification_001.go/002
+// JUSTIFY(languages): justification_001.go/nothing-to-justify
+func justification002() {}
+
+// JUSTIFY(justification/unknown-code): justification_001.go/003
+// JUSTIFY(no-panic/R999): justification_001.go/wrong-code
+func justification003() {}
@@ -0,0 +1,13 @@
+// JUSTIFY-FILE(no-panic/R001): justification_002.go/file
+
+package foo
+
+func justification004() {
+	panic("covered by the file justification")
+}
+
+func justification005(i int) {
+	if i < 0 {
+		panic("covered as well")
+	}
+}
@@ -0,0 +1,20 @@
+package foo
+
+// JUSTIFY(no-panic/R001): justification_003.go/enclosing
+func justification006(i int) {
+	if i < 0 {
+		panic("nested blocks are covered")
+	}
+	panic("covered by the justification on the function")
+}
+
+// JUSTIFY-BEGIN(no-panic/R001): justification_003.go/region
+func justification007() {
+	panic("covered by the region")
+}
+
+func justification008() {
+	panic("covered by the region as well")
+}
+
+// JUSTIFY-END
@@ -0,0 +1,22 @@
+package foo;
+
+import java.util.ArrayList;
+import java.util.List;
+
+// JUSTIFY(languages): languages_001.java/001
+public class Forbidden {
+    private final List<String> names = new ArrayList<>();
+
+    public void add(String name) {
+        names.add(name);
+    }
+
+    public int forbidden() {
+        return names.size();
+    }
+}
+
+/* JUSTIFY(languages): languages_001.java/002 */
+interface Named {
+    String forbidden();
+}
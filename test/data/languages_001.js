@@ -0,0 +1,22 @@
+const fs = require("fs");
+
+function countLines(path) {
+  const content = fs.readFileSync(path, "utf8");
+  return content.split("\n").length;
+}
+
+// JUSTIFY(languages): languages_001.js/001
+function forbidden() {
+  return countLines(__filename);
+}
+
+class Counter {
+  #value = 0;
+
+  increment() {
+    this.#value += 1;
+    return this.#value;
+  }
+}
+
+module.exports = { countLines, Counter };
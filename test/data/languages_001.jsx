@@ -0,0 +1,8 @@
+import React from "react";
+
+export function Greeting({ name }) {
+  return <p className="greeting">Hello, {name}!</p>;
+}
+
+/* JUSTIFY(languages): languages_001.jsx/001 */
+export const forbidden = () => <Greeting name="world" />;
@@ -0,0 +1,9 @@
+import { readFile } from "node:fs/promises";
+
+export async function loadConfig(path) {
+  const content = await readFile(path, "utf8");
+  return JSON.parse(content);
+}
+
+// JUSTIFY(languages): languages_001.mjs/001
+export const forbidden = await loadConfig("config.json");
@@ -0,0 +1,28 @@
+import os
+from collections import defaultdict
+
+
+def count_words(path):
+    counts = defaultdict(int)
+    with open(path) as f:
+        for line in f:
+            for word in line.split():
+                counts[word] += 1
+    return counts
+
+
+# JUSTIFY(languages): languages_001.py/001
+def forbidden():
+    return os.getcwd()
+
+
+class Greeter:
+    def __init__(self, name):
+        self.name = name
+
+    def greet(self):
+        return f"Hello, {self.name}!"
+
+
+# JUSTIFY(languages): languages_001.py/002
+forbidden()
@@ -0,0 +1,24 @@
+use std::collections::HashMap;
+
+/// Counts how often every word appears in the text.
+pub fn count_words(text: &str) -> HashMap<&str, usize> {
+    let mut counts = HashMap::new();
+    for word in text.split_whitespace() {
+        *counts.entry(word).or_insert(0) += 1;
+    }
+    counts
+}
+
+// JUSTIFY(languages): languages_001.rs/001
+pub fn forbidden() -> Option<usize> {
+    count_words("a b a").get("a").copied()
+}
+
+/* JUSTIFY(languages): languages_001.rs/002 */
+impl<'a> From<&'a str> for Wrapper<'a> {
+    fn from(forbidden: &'a str) -> Self {
+        Wrapper(forbidden)
+    }
+}
+
+pub struct Wrapper<'a>(&'a str);
@@ -0,0 +1,20 @@
+interface Point {
+  x: number;
+  y: number;
+}
+
+type Shape = { kind: "circle"; radius: number } | { kind: "square"; size: number };
+
+export function area(shape: Shape): number {
+  switch (shape.kind) {
+    case "circle":
+      return Math.PI * shape.radius ** 2;
+    case "square":
+      return shape.size ** 2;
+  }
+}
+
+// JUSTIFY(languages): languages_001.ts/001
+export function forbidden<T extends Point>(points: T[]): number {
+  return points.reduce((sum, p) => sum + p.x * p.y, 0);
+}
@@ -0,0 +1,18 @@
+import React from "react";
+
+type Props = {
+  items: string[];
+};
+
+export function List({ items }: Props): JSX.Element {
+  return (
+    <ul>
+      {items.map((item) => (
+        <li key={item}>{item}</li>
+      ))}
+    </ul>
+  );
+}
+
+// JUSTIFY(languages): languages_001.tsx/001
+export const forbidden = <List items={["a", "b"] as string[]} />;
@@ -0,0 +1,18 @@
+package foo
+
+import "errors"
+
+func mustPositive(i int) int {
+	if i < 0 {
+		// JUSTIFY(no-panic/R001): rules_001.go/001
+		panic("negative")
+	}
+	return i
+}
+
+func checkPositive(i int) error {
+	if i < 0 {
+		return errors.New("negative")
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+import logging
+
+
+def compute(values):
+    total = sum(values)
+    # JUSTIFY(no-print): rules_001.py/001
+    print(total)
+    logging.info("total is %d", total)
+    return total
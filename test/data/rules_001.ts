@@ -0,0 +1,7 @@
+export function compute(values: number[]): number {
+  const total = values.reduce((a, b) => a + b, 0);
+  // JUSTIFY(no-console-log): rules_001.ts/001
+  console.log(total);
+  console.error("never reported");
+  return total;
+}
@@ -0,0 +1,12 @@
+package foo
+
+import (
+	// JUSTIFY(unwanted-imports): unwanted_imports_002.go/001
+	legacy "io/ioutil"
+	"os"
+)
+
+func readLegacy() {
+	legacy.ReadDir("foo")
+	os.Exit(0)
+}
@@ -0,0 +1,12 @@
+package foo
+
+import (
+	// JUSTIFY(unwanted-imports/E002): unwanted_imports_003.go/001
+	"log"
+	"log/slog"
+)
+
+func logBoth() {
+	log.Println("foo")
+	slog.Info("foo")
+}
@@ -0,0 +1,13 @@
+package foo
+
+import (
+	"fmt"
+	"io/ioutil" // JUSTIFY(unwanted-imports): unwanted_imports_004.go/001
+	"log"       /* JUSTIFY(unwanted-imports/E002): unwanted_imports_004.go/002 */
+)
+
+func readTrailing() {
+	ioutil.ReadDir("foo")
+	log.Print("foo")
+	fmt.Printf("foo")
+}
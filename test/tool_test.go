@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/unnamedtiger/check/common"
 	"github.com/unnamedtiger/check/plugins/unwanted_imports"
 )
@@ -15,6 +16,9 @@ type testFile struct {
 	Encountered   bool
 	FoundTags     []string
 	FoundMessages []string
+
+	// positions of justifications the built-in checker reported as invalid, they are expected in the test data
+	InvalidJustifications [][2]uint32
 }
 
 type testJustification struct {
@@ -23,10 +27,50 @@ type testJustification struct {
 	Found   bool
 }
 
+// NOTE: this plugin only exists for the system tests, it makes sure every language is parsed with the right grammar and supports justifications
+var languagesPlugin = &common.Plugin{
+	Name: "languages",
+	Doc:  "reports files with syntax errors and top-level declarations mentioning forbidden",
+	Run: func(a *common.Analysis) error {
+		if a.Root.HasError() {
+			a.ReportFile(a.FilePath, "unable to parse file")
+		}
+		for i := 0; i < int(a.Root.NamedChildCount()); i++ {
+			child := a.Root.NamedChild(i)
+			if mentionsForbidden(child, a.Content) {
+				a.Reportf(child, "declaration mentions forbidden: %s", child.Type())
+			}
+		}
+		return nil
+	},
+}
+
+func mentionsForbidden(n *sitter.Node, content []byte) bool {
+	if n.NamedChildCount() == 0 {
+		return n.Content(content) == "forbidden"
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if mentionsForbidden(n.NamedChild(i), content) {
+			return true
+		}
+	}
+	return false
+}
+
 func TestTool(t *testing.T) {
+	for _, lang := range common.Languages() {
+		languagesPlugin.Extensions = append(languagesPlugin.Extensions, lang.Extensions...)
+	}
 	plugins := []*common.Plugin{
 		unwanted_imports.Plugin,
+		languagesPlugin,
 	}
+	rules, err := common.LoadRules("rules.yaml")
+	if err != nil {
+		fmt.Println(err)
+		t.FailNow()
+	}
+	plugins = append(plugins, rules...)
 
 	directories := []string{"data"}
 
@@ -63,6 +107,9 @@ func TestTool(t *testing.T) {
 			t.FailNow()
 		}
 
+		if vio.PluginName == "justification" {
+			tf.InvalidJustifications = append(tf.InvalidJustifications, [2]uint32{vio.StartLine, vio.StartColumn})
+		}
 		tf.FoundTags = append(tf.FoundTags, vio.Justification.Tag)
 		tf.FoundMessages = append(tf.FoundMessages, vio.Justification.Message)
 
@@ -79,7 +126,13 @@ func TestTool(t *testing.T) {
 		justifications := common.ExtractJustifications(string(content), 0, 0)
 		testJustifications := []testJustification{}
 		for _, j := range justifications {
-			testJustifications = append(testJustifications, testJustification{j.Tag, j.Message, false})
+			invalid := false
+			for _, pos := range tf.InvalidJustifications {
+				if pos == [2]uint32{j.StartLine, j.StartColumn} {
+					invalid = true
+				}
+			}
+			testJustifications = append(testJustifications, testJustification{j.Tag, j.Message, invalid})
 		}
 
 		for i := 0; i < len(tf.FoundTags); i++ {
@@ -7,7 +7,10 @@ require (
 	github.com/unnamedtiger/check/plugins/unwanted_imports v0.0.0
 )
 
-require github.com/smacker/go-tree-sitter v0.0.0-20240625050157-a31a98a7c0f6 // indirect
+require (
+	github.com/smacker/go-tree-sitter v0.0.0-20240625050157-a31a98a7c0f6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
 
 replace (
 	github.com/unnamedtiger/check/common => ../common